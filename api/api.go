@@ -1,10 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/bkasin/gogios"
 	"github.com/bkasin/gogios/helpers/config"
@@ -19,6 +21,20 @@ type status struct {
 	Status     string
 	GoodCount  int
 	TotalCount int
+	ExitCode   int
+	Duration   float64    // Seconds
+	Expires    *time.Time `json:",omitempty"` // Certificate expiry, for tls checks
+}
+
+// run is one row of a check's history
+type run struct {
+	Asof      time.Time
+	Status    string
+	ExitCode  int
+	Duration  float64 // Seconds
+	Stdout    string
+	Stderr    string
+	Truncated bool // Stdout or Stderr was cut short
 }
 
 var (
@@ -38,7 +54,7 @@ func API() {
 	apiLogger = logger.Init("APILog", config.Conf.Options.Verbose, true, log)
 	defer apiLogger.Close()
 
-	primaryDB = config.Conf.Databases[0].Database
+	primaryDB = config.DB
 
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/api/", apiHome)
@@ -48,6 +64,13 @@ func API() {
 	// Check routes
 	router.HandleFunc("/api/getAllChecks", getAllChecks)
 	router.HandleFunc("/api/getCheck/{check}", getCheckStatus)
+	router.HandleFunc("/api/getCheckHistory/{check}", getCheckHistory)
+
+	// Plugin routes
+	router.HandleFunc("/api/getPlugins", getPlugins)
+
+	// Prometheus scrapes this, so it is not under /api
+	router.HandleFunc("/metrics", getMetrics)
 
 	// User routes
 	router.HandleFunc("/api/login", apiLogin)
@@ -64,3 +87,10 @@ func apiHome(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "API home page")
 	w.WriteHeader(http.StatusTeapot)
 }
+
+// writeError sends a JSON error body with the given status code
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": message})
+}
@@ -1,19 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/bkasin/gogios"
 	"github.com/gorilla/mux"
 )
 
 var allChecks []status
 
-func getAllStatuses() []status {
-	allPrev, err := primaryDB.GetAllChecks()
+func getAllStatuses(ctx context.Context) ([]status, error) {
+	allPrev, err := primaryDB.GetAllChecks(ctx)
 	if err != nil {
-		apiLogger.Errorf("Could not read database, error output:\n%s", err.Error())
+		return nil, err
 	}
 
 	var allChecks []status
@@ -25,21 +28,38 @@ func getAllStatuses() []status {
 			Status:     allPrev[i].Status,
 			GoodCount:  allPrev[i].GoodCount,
 			TotalCount: allPrev[i].TotalCount,
+			ExitCode:   allPrev[i].ExitCode,
+			Duration:   allPrev[i].Duration.Seconds(),
+			Expires:    allPrev[i].Expires,
 		})
 	}
 
-	return allChecks
+	return allChecks, nil
 }
 
 func getCheckStatus(w http.ResponseWriter, r *http.Request) {
 	checkID := mux.Vars(r)["check"]
 
-	data, err := primaryDB.GetCheck(checkID, "id")
-	if err != nil {
+	data, err := primaryDB.GetCheck(r.Context(), checkID, "id")
+	if errors.Is(err, gogios.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "No check with that ID")
+		return
+	} else if err != nil {
 		apiLogger.Errorf("Could not get check by ID, error:\n%s", err.Error())
+		writeError(w, http.StatusServiceUnavailable, "Could not read the database")
+		return
 	}
 
-	status := status{ID: strconv.FormatUint(uint64(data.ID), 10), Title: data.Title, Status: data.Status, GoodCount: data.GoodCount, TotalCount: data.TotalCount}
+	status := status{
+		ID:         strconv.FormatUint(uint64(data.ID), 10),
+		Title:      data.Title,
+		Status:     data.Status,
+		GoodCount:  data.GoodCount,
+		TotalCount: data.TotalCount,
+		ExitCode:   data.ExitCode,
+		Duration:   data.Duration.Seconds(),
+		Expires:    data.Expires,
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
@@ -47,8 +67,67 @@ func getCheckStatus(w http.ResponseWriter, r *http.Request) {
 
 func getAllChecks(w http.ResponseWriter, r *http.Request) {
 	apiLogger.Infoln("Get All Checks")
-	allChecks := getAllStatuses()
+	allChecks, err := getAllStatuses(r.Context())
+	if err != nil {
+		apiLogger.Errorf("Could not read database, error output:\n%s", err.Error())
+		writeError(w, http.StatusServiceUnavailable, "Could not read the database")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(allChecks)
 }
+
+// getCheckHistory returns the most recent runs of a check, newest first. The
+// amount query parameter sets how many, 25 by default
+func getCheckHistory(w http.ResponseWriter, r *http.Request) {
+	checkID := mux.Vars(r)["check"]
+
+	amount := 25
+	if raw := r.URL.Query().Get("amount"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "amount must be a positive number")
+			return
+		}
+		amount = n
+	}
+
+	check, err := primaryDB.GetCheck(r.Context(), checkID, "id")
+	if errors.Is(err, gogios.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "No check with that ID")
+		return
+	} else if err != nil {
+		apiLogger.Errorf("Could not get check by ID, error:\n%s", err.Error())
+		writeError(w, http.StatusServiceUnavailable, "Could not read the database")
+		return
+	}
+
+	history, err := primaryDB.GetCheckHistory(r.Context(), check, amount)
+	if err != nil {
+		apiLogger.Errorf("Could not get history of %s, error:\n%s", check.Title, err.Error())
+		writeError(w, http.StatusServiceUnavailable, "Could not read the database")
+		return
+	}
+
+	runs := []run{}
+	for _, row := range history {
+		entry := run{
+			ExitCode:  row.ExitCode,
+			Duration:  row.Duration.Seconds(),
+			Stdout:    row.Output,
+			Stderr:    row.Stderr,
+			Truncated: row.Truncated,
+		}
+		if row.Asof != nil {
+			entry.Asof = *row.Asof
+		}
+		if row.Status != nil {
+			entry.Status = *row.Status
+		}
+		runs = append(runs, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
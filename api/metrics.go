@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/bkasin/gogios/helpers/metrics"
+)
+
+// getMetrics exposes every check and gogios' own metrics for Prometheus. The
+// internal metrics are still served when the database can not be read, since
+// that is when they matter most
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	checks, err := primaryDB.GetAllChecks(r.Context())
+	if err != nil {
+		apiLogger.Errorf("Could not read database for metrics, error output:\n%s", err.Error())
+		checks = nil
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Write(w, checks); err != nil {
+		apiLogger.Errorf("Could not write metrics, error output:\n%s", err.Error())
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bkasin/gogios/databases/manager"
+	"github.com/bkasin/gogios/helpers/config"
+)
+
+type plugin struct {
+	Type        string
+	Name        string
+	Alias       string
+	Timeout     string   `json:",omitempty"`
+	Filters     []string `json:",omitempty"`
+	MinSeverity int      `json:",omitempty"`
+
+	Health *manager.Health `json:",omitempty"`
+}
+
+// getPlugins lists the configured databases, notifiers and outputs so instances of the
+// same plugin can be told apart by their aliases. Databases include their health
+func getPlugins(w http.ResponseWriter, r *http.Request) {
+	var plugins []plugin
+
+	health := config.DB.Health()
+	for i, database := range config.Conf.Databases {
+		p := plugin{Type: "database", Name: database.Config.Name, Alias: database.Config.Alias, Health: &health[i]}
+		if database.Config.Timeout > 0 {
+			p.Timeout = database.Config.Timeout.String()
+		}
+		plugins = append(plugins, p)
+	}
+
+	for _, notifier := range config.Conf.Notifiers {
+		p := plugin{
+			Type:        "notifier",
+			Name:        notifier.Config.Name,
+			Alias:       notifier.Config.Alias,
+			Filters:     notifier.Config.Filters,
+			MinSeverity: notifier.Config.MinSeverity,
+		}
+		if notifier.Config.Timeout > 0 {
+			p.Timeout = notifier.Config.Timeout.String()
+		}
+		plugins = append(plugins, p)
+	}
+
+	for _, output := range config.Conf.Outputs {
+		p := plugin{Type: "output", Name: output.Config.Name, Alias: output.Config.Alias, Filters: output.Config.Filters}
+		if output.Config.Timeout > 0 {
+			p.Timeout = output.Config.Timeout.String()
+		}
+		plugins = append(plugins, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plugins)
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 
 	"github.com/bkasin/gogios"
-	"github.com/bkasin/gogios/helpers/config"
 	"github.com/bkasin/gogios/users"
 )
 
@@ -31,7 +30,7 @@ func createNewUser(w http.ResponseWriter, r *http.Request) {
 		apiLogger.Errorf("Create new user JSON error:\n%v", err.Error())
 	}
 
-	err = users.CreateUser(user, config.Conf)
+	err = users.CreateUser(r.Context(), user)
 	if err != nil {
 		apiLogger.Errorf("Create new user error:\n%v", err.Error())
 		resp = map[string]interface{}{"status": "Failed", "error": err.Error()}
@@ -69,7 +68,7 @@ func apiLogin(w http.ResponseWriter, r *http.Request) {
 		apiLogger.Errorf("Authenitcation test JSON error:\n%v", err.Error())
 	}
 
-	resp = users.Login(user.Username, user.Password, primaryDB)
+	resp = users.Login(r.Context(), user.Username, user.Password, primaryDB)
 
 	if resp["status"] == false {
 		statusCode = 401 // Unauthorized
@@ -0,0 +1,86 @@
+package gogios
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checker is implemented by the check types built into gogios. They run
+// in-process instead of as a command in sh, and are picked in the check list
+// with "type", their settings going in "options".
+type Checker interface {
+	Description() string
+
+	// Init validates the options from the check list and fills in defaults.
+	Init() error
+	// Run performs the check once. It must give up once ctx is done.
+	Run(ctx context.Context) CheckResult
+}
+
+// CheckResult is the outcome of one run of a check
+type CheckResult struct {
+	Status   string     // StatusSuccess, StatusWarning or StatusFailed
+	Output   string     // What the check found, stored like the stdout of a command
+	Stderr   string     // Only set by commands
+	ExitCode int        // The exit code of a command, or StatusCode(Status) for the other types
+	Perfdata []Perfdata // Measurements taken during the run
+	Expires  *time.Time // When the certificate that was checked expires, if there was one
+}
+
+// FullOutput returns the output with the perfdata added to its first line, the
+// way Nagios plugins print them
+func (r CheckResult) FullOutput() string {
+	if len(r.Perfdata) == 0 {
+		return r.Output
+	}
+
+	first, rest, multiline := strings.Cut(r.Output, "\n")
+	output := first + " | " + FormatPerfdata(r.Perfdata)
+	if multiline {
+		output += "\n" + rest
+	}
+
+	return output
+}
+
+// Perfdata is a measurement taken while running a check, such as a response
+// time. It follows the performance data of Nagios plugins
+type Perfdata struct {
+	Label    string  `json:"label"`
+	Value    float64 `json:"value"`
+	Unit     string  `json:"unit,omitempty"`     // s, ms, %, B or c, or empty for a plain number
+	Warning  float64 `json:"warning,omitempty"`  // Threshold for a warning, 0 if there is none
+	Critical float64 `json:"critical,omitempty"` // Threshold for a failure, 0 if there is none
+}
+
+// String formats the measurement as label=value[unit];[warning];[critical]
+func (p Perfdata) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " '=") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+
+	s := label + "=" + formatPerf(p.Value) + p.Unit
+	if p.Warning != 0 || p.Critical != 0 {
+		s += ";" + formatPerf(p.Warning) + ";" + formatPerf(p.Critical)
+	}
+
+	return s
+}
+
+// FormatPerfdata joins measurements the way Nagios plugins print them after
+// the | in their output
+func FormatPerfdata(perfdata []Perfdata) string {
+	parts := make([]string, 0, len(perfdata))
+	for _, p := range perfdata {
+		parts = append(parts, p.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func formatPerf(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,19 @@
+package all
+
+import (
+	_ "github.com/bkasin/gogios/checks/dns"
+	_ "github.com/bkasin/gogios/checks/ftp"
+	_ "github.com/bkasin/gogios/checks/http"
+	_ "github.com/bkasin/gogios/checks/imap"
+	_ "github.com/bkasin/gogios/checks/ldap"
+	_ "github.com/bkasin/gogios/checks/mysql"
+	_ "github.com/bkasin/gogios/checks/ping"
+	_ "github.com/bkasin/gogios/checks/pop3"
+	_ "github.com/bkasin/gogios/checks/postgres"
+	_ "github.com/bkasin/gogios/checks/redis"
+	_ "github.com/bkasin/gogios/checks/sftp"
+	_ "github.com/bkasin/gogios/checks/smtp"
+	_ "github.com/bkasin/gogios/checks/ssh"
+	_ "github.com/bkasin/gogios/checks/tcp"
+	_ "github.com/bkasin/gogios/checks/tls"
+)
@@ -0,0 +1,360 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var types = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"SRV":   dnsmessage.TypeSRV,
+	"NS":    dnsmessage.TypeNS,
+}
+
+var rcodes = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// DNS asks a server for one name and checks the records it answers with
+type DNS struct {
+	Server   string `json:"server"`   // host or host:port. The port is 53, or 853 for DoT, by default
+	Protocol string `json:"protocol"` // udp, tcp or dot. UDP falls back to TCP if the answer is truncated
+	checks.TLSOptions
+
+	Name    string `json:"name"`
+	Record  string `json:"record"`  // A, AAAA, CNAME, MX, TXT, SRV or NS
+	Recurse bool   `json:"recurse"` // Ask for recursion, for checking a resolver rather than an authoritative server
+
+	// Answers that must be returned, written the way dig prints them, such
+	// as "10 mail.example.com" for MX or "0 5 5060 sip.example.com" for SRV
+	Expected []string `json:"expected"`
+	// Fail if anything other than the expected answers is returned
+	Exact bool `json:"exact"`
+	// Response code to expect, NOERROR by default
+	RCode string `json:"rcode"`
+	// Bounds in seconds for the TTL of every answer, 0 is not checked
+	MinTTL uint32 `json:"min_ttl"`
+	MaxTTL uint32 `json:"max_ttl"`
+
+	// Response times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	name      dnsmessage.Name
+	qtype     dnsmessage.Type
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (d *DNS) Description() string {
+	return "Query a DNS server and check the answers, response code and TTLs"
+}
+
+// Init checks the options
+func (d *DNS) Init() error {
+	d.Protocol = strings.ToLower(d.Protocol)
+	if d.Protocol == "" {
+		d.Protocol = "udp"
+	}
+	port := "53"
+	switch d.Protocol {
+	case "udp", "tcp":
+	case "dot":
+		port = "853"
+	default:
+		return fmt.Errorf("protocol must be udp, tcp or dot, not %q", d.Protocol)
+	}
+
+	if d.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	host, _, err := net.SplitHostPort(d.Server)
+	if err != nil {
+		host = strings.Trim(d.Server, "[]")
+		d.Server = net.JoinHostPort(host, port)
+	}
+
+	if d.Protocol == "dot" {
+		d.tlsConfig, err = d.TLSConfig(host)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.Record = strings.ToUpper(d.Record)
+	if d.Record == "" {
+		d.Record = "A"
+	}
+	var ok bool
+	d.qtype, ok = types[d.Record]
+	if !ok {
+		return fmt.Errorf("record must be one of A, AAAA, CNAME, MX, TXT, SRV or NS, not %q", d.Record)
+	}
+
+	if d.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	d.name, err = dnsmessage.NewName(strings.TrimSuffix(d.Name, ".") + ".")
+	if err != nil {
+		return fmt.Errorf("invalid name %q: %s", d.Name, err)
+	}
+
+	d.RCode = strings.ToUpper(d.RCode)
+	if d.RCode == "" {
+		d.RCode = "NOERROR"
+	}
+
+	for i, expected := range d.Expected {
+		d.Expected[i] = normalize(d.qtype, expected)
+	}
+
+	return nil
+}
+
+// Run sends the query and checks the response
+func (d *DNS) Run(ctx context.Context) gogios.CheckResult {
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: d.Recurse},
+		Questions: []dnsmessage.Question{{Name: d.name, Type: d.qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return checks.Failed(err)
+	}
+
+	start := time.Now()
+	resp, err := d.exchange(ctx, d.Protocol, packed, query.ID)
+	if err == nil && resp.Truncated && d.Protocol == "udp" {
+		resp, err = d.exchange(ctx, "tcp", packed, query.ID)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return checks.Failed(fmt.Errorf("query to %s failed: %s", d.Server, err))
+	}
+
+	var answers []string
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != d.qtype {
+			continue
+		}
+		answers = append(answers, normalize(d.qtype, format(rr.Body)))
+	}
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: d.WarningTime.Duration.Seconds(), Critical: d.CriticalTime.Duration.Seconds()},
+		{Label: "answers", Value: float64(len(answers))},
+	}
+
+	rcode := rcodes[resp.RCode]
+	if rcode == "" {
+		rcode = resp.RCode.String()
+	}
+	output := fmt.Sprintf("%s from %s with %d %s records in %s", rcode, d.Server, len(answers), d.Record, elapsed.Round(time.Millisecond))
+	if len(answers) > 0 {
+		output += "\n" + strings.Join(answers, "\n")
+	}
+
+	problems := d.assert(resp, rcode, answers)
+	if len(problems) > 0 {
+		return checks.Result(gogios.StatusFailed, output+"\n"+strings.Join(problems, "\n"), perfdata...)
+	}
+
+	return checks.Result(checks.Threshold(elapsed.Seconds(), d.WarningTime.Duration.Seconds(), d.CriticalTime.Duration.Seconds()), output, perfdata...)
+}
+
+// assert returns every way the response differs from what was expected
+func (d *DNS) assert(resp *dnsmessage.Message, rcode string, answers []string) []string {
+	var problems []string
+
+	if rcode != d.RCode {
+		problems = append(problems, fmt.Sprintf("expected %s", d.RCode))
+	}
+	if rcode == "NOERROR" && d.RCode == "NOERROR" && len(answers) == 0 {
+		problems = append(problems, fmt.Sprintf("no %s records", d.Record))
+	}
+
+	got := map[string]bool{}
+	for _, answer := range answers {
+		got[answer] = true
+	}
+	want := map[string]bool{}
+	for _, expected := range d.Expected {
+		want[expected] = true
+		if !got[expected] {
+			problems = append(problems, fmt.Sprintf("missing %s", expected))
+		}
+	}
+	if d.Exact {
+		for _, answer := range answers {
+			if !want[answer] {
+				problems = append(problems, fmt.Sprintf("unexpected %s", answer))
+			}
+		}
+	}
+
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != d.qtype {
+			continue
+		}
+		if d.MinTTL > 0 && rr.Header.TTL < d.MinTTL {
+			problems = append(problems, fmt.Sprintf("TTL of %s is %d, below %d", format(rr.Body), rr.Header.TTL, d.MinTTL))
+		}
+		if d.MaxTTL > 0 && rr.Header.TTL > d.MaxTTL {
+			problems = append(problems, fmt.Sprintf("TTL of %s is %d, above %d", format(rr.Body), rr.Header.TTL, d.MaxTTL))
+		}
+	}
+
+	return problems
+}
+
+// exchange sends the query and reads the response with the same ID
+func (d *DNS) exchange(ctx context.Context, protocol string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var conn net.Conn
+	var err error
+	if protocol == "dot" {
+		dialer := tls.Dialer{Config: d.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", d.Server)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, protocol, d.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	for {
+		var raw []byte
+		if protocol == "udp" {
+			raw, err = exchangeUDP(conn, query)
+		} else {
+			raw, err = exchangeStream(conn, query)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(raw); err != nil {
+			return nil, fmt.Errorf("invalid response: %s", err)
+		}
+		// A late answer to an earlier query is skipped over UDP
+		if resp.ID == id && resp.Response {
+			return &resp, nil
+		}
+		if protocol != "udp" {
+			return nil, errors.New("response ID does not match the query")
+		}
+		query = nil
+	}
+}
+
+// exchangeUDP sends the query unless it is nil, then reads one packet
+func exchangeUDP(conn net.Conn, query []byte) ([]byte, error) {
+	if query != nil {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// exchangeStream sends and reads messages with the two byte length prefix
+// used over TCP
+func exchangeStream(conn net.Conn, query []byte) ([]byte, error) {
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// format prints a record the way dig does
+func format(body dnsmessage.ResourceBody) string {
+	switch r := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(r.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(r.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return r.CNAME.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", r.Pref, r.MX.String())
+	case *dnsmessage.TXTResource:
+		return strings.Join(r.TXT, "")
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target.String())
+	case *dnsmessage.NSResource:
+		return r.NS.String()
+	default:
+		return body.GoString()
+	}
+}
+
+// normalize makes answers comparable, so that "2001:DB8::1" matches
+// "2001:db8::1" and "Example.com." matches "example.com"
+func normalize(qtype dnsmessage.Type, answer string) string {
+	switch qtype {
+	case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+		if ip := net.ParseIP(answer); ip != nil {
+			return ip.String()
+		}
+		return answer
+	case dnsmessage.TypeTXT:
+		return answer
+	default:
+		return strings.ToLower(strings.TrimSuffix(answer, "."))
+	}
+}
+
+func init() {
+	checks.Add("dns", func() gogios.Checker {
+		return &DNS{}
+	})
+}
@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer is a tiny authoritative server for example.com. Over UDP the TXT
+// record is too big and the answer is truncated
+func answer(query []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil
+	}
+
+	q := msg.Questions[0]
+	msg.Header.Response = true
+	msg.Header.Authoritative = true
+	header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 300}
+
+	switch {
+	case q.Name.String() != "example.com.":
+		msg.Header.RCode = dnsmessage.RCodeNameError
+	case q.Type == dnsmessage.TypeA:
+		msg.Answers = []dnsmessage.Resource{
+			{Header: header, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}},
+			{Header: header, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}},
+		}
+	case q.Type == dnsmessage.TypeMX:
+		mx, _ := dnsmessage.NewName("Mail.example.com.")
+		msg.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.MXResource{Pref: 10, MX: mx}}}
+	case q.Type == dnsmessage.TypeTXT && udp:
+		msg.Header.Truncated = true
+	case q.Type == dnsmessage.TypeTXT:
+		msg.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}}}
+	}
+
+	packed, _ := msg.Pack()
+	return packed
+}
+
+// serve answers over UDP and TCP on the same port
+func serve(t *testing.T) string {
+	packet, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { packet.Close() })
+
+	listener, err := net.Listen("tcp", packet.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := packet.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			packet.WriteTo(answer(buf[:n], true), addr)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			io.ReadFull(conn, length[:])
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			io.ReadFull(conn, query)
+
+			resp := answer(query, false)
+			binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+			conn.Write(append(length[:], resp...))
+			conn.Close()
+		}
+	}()
+
+	return packet.LocalAddr().String()
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "dns", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	server := `"server": "` + serve(t) + `"`
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"a", `{` + server + `, "name": "example.com", "expected": ["192.0.2.1"]}`, gogios.StatusSuccess},
+		{"a over tcp", `{` + server + `, "protocol": "tcp", "name": "example.com", "expected": ["192.0.2.2"]}`, gogios.StatusSuccess},
+		{"missing answer", `{` + server + `, "name": "example.com", "expected": ["192.0.2.3"]}`, gogios.StatusFailed},
+		{"exact", `{` + server + `, "name": "example.com", "expected": ["192.0.2.1"], "exact": true}`, gogios.StatusFailed},
+		{"mx", `{` + server + `, "name": "example.com.", "record": "mx", "expected": ["10 mail.example.com."]}`, gogios.StatusSuccess},
+		{"truncated", `{` + server + `, "name": "example.com", "record": "TXT", "expected": ["v=spf1 -all"]}`, gogios.StatusSuccess},
+		{"no records", `{` + server + `, "name": "example.com", "record": "AAAA"}`, gogios.StatusFailed},
+		{"nxdomain", `{` + server + `, "name": "nothing.example.com"}`, gogios.StatusFailed},
+		{"expected nxdomain", `{` + server + `, "name": "nothing.example.com", "rcode": "NXDOMAIN"}`, gogios.StatusSuccess},
+		{"ttl", `{` + server + `, "name": "example.com", "min_ttl": 60, "max_ttl": 3600}`, gogios.StatusSuccess},
+		{"ttl too long", `{` + server + `, "name": "example.com", "max_ttl": 60}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	result := run(t, `{"server": "`+serve(t)+`", "name": "example.com"}`)
+	if !strings.HasPrefix(result.Output, "NOERROR from 127.0.0.1:") || !strings.HasSuffix(result.Output, "\n192.0.2.1\n192.0.2.2") {
+		t.Errorf("Output was wrong, got: %s", result.Output)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{"name": "example.com"}`,
+		`{"server": "127.0.0.1"}`,
+		`{"server": "127.0.0.1", "name": "example.com", "record": "PTR"}`,
+		`{"server": "127.0.0.1", "name": "example.com", "protocol": "doh"}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "dns", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
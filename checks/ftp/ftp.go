@@ -0,0 +1,371 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// FTP logs in to an FTP server, lists a directory and optionally uploads a
+// file and downloads it again
+type FTP struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // 21 by default, or 990 with tls
+
+	// Connect over TLS from the start, or upgrade with AUTH TLS. Transfers
+	// are then protected too
+	TLS      bool `json:"tls"`
+	StartTLS bool `json:"starttls"`
+	checks.TLSOptions
+
+	// anonymous by default. The password is a secret reference, such as
+	// "env:FTP_PASSWORD"
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Directory to list, the login directory by default, and names that
+	// must be in it
+	Directory string   `json:"directory"`
+	Expected  []string `json:"expected"`
+
+	// Path to upload a test file to, download again and delete. The check
+	// fails unless what comes back hashes the same as what was sent
+	RoundTrip string `json:"round_trip"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	password  string
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (f *FTP) Description() string {
+	return "Log in to an FTP server, list a directory and optionally transfer a test file"
+}
+
+// Init checks the options
+func (f *FTP) Init() error {
+	if f.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if f.TLS && f.StartTLS {
+		return fmt.Errorf("tls and starttls cannot both be set")
+	}
+	if f.Port == 0 {
+		f.Port = 21
+		if f.TLS {
+			f.Port = 990
+		}
+	}
+	f.address = net.JoinHostPort(f.Host, strconv.Itoa(f.Port))
+
+	if f.Username == "" {
+		f.Username = "anonymous"
+	}
+
+	var err error
+	f.password, err = helpers.ResolveSecret(f.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	if f.TLS || f.StartTLS {
+		f.tlsConfig, err = f.TLSConfig(f.Host)
+		if err != nil {
+			return err
+		}
+		// Many servers want the data connections to resume the control session
+		f.tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	return nil
+}
+
+// Run logs in, lists the directory and does the round trip
+func (f *FTP) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	if f.TLS {
+		dialer := tls.Dialer{Config: f.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", f.address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", f.address)
+	}
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	c := &client{text: textproto.NewConn(conn), host: conn.RemoteAddr().(*net.TCPAddr).IP}
+	_, greeting, err := c.text.ReadResponse(220)
+	if err != nil {
+		return fail("Bad greeting: %s", err)
+	}
+	output = append(output, "220 "+greeting)
+
+	if f.StartTLS {
+		if _, err := c.command(234, "AUTH TLS"); err != nil {
+			return fail("AUTH TLS failed: %s", err)
+		}
+		tlsConn := tls.Client(conn, f.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail("TLS handshake failed: %s", err)
+		}
+		c.text = textproto.NewConn(tlsConn)
+		output = append(output, "Started TLS")
+	}
+
+	if err := c.login(f.Username, f.password); err != nil {
+		return fail("Login as %s failed: %s", f.Username, err)
+	}
+	output = append(output, "Logged in as "+f.Username)
+
+	if f.tlsConfig != nil {
+		if _, err := c.command(200, "PBSZ 0"); err != nil {
+			return fail("PBSZ failed: %s", err)
+		}
+		if _, err := c.command(200, "PROT P"); err != nil {
+			return fail("Protecting transfers failed: %s", err)
+		}
+		c.tlsConfig = f.tlsConfig
+	}
+	if _, err := c.command(200, "TYPE I"); err != nil {
+		return fail("Switching to binary failed: %s", err)
+	}
+
+	list := "NLST"
+	directory := "the login directory"
+	if f.Directory != "" {
+		list += " " + f.Directory
+		directory = f.Directory
+	}
+	listing, err := c.transfer(ctx, nil, list)
+	if err != nil {
+		return fail("Listing %s failed: %s", directory, err)
+	}
+	names := map[string]bool{}
+	for _, line := range strings.Split(string(listing), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			// Some servers give the names with the directory in front
+			names[name[strings.LastIndex(name, "/")+1:]] = true
+		}
+	}
+	output = append(output, fmt.Sprintf("%s has %d entries", directory, len(names)))
+	for _, name := range f.Expected {
+		if !names[name] {
+			return fail("%s is not in %s", name, directory)
+		}
+	}
+
+	if f.RoundTrip != "" {
+		size, err := c.roundTrip(ctx, f.RoundTrip)
+		if err != nil {
+			return fail("Round trip through %s failed: %s", f.RoundTrip, err)
+		}
+		output = append(output, fmt.Sprintf("Uploaded and downloaded %d bytes through %s", size, f.RoundTrip))
+	}
+
+	c.command(221, "QUIT")
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: f.WarningTime.Duration.Seconds(), Critical: f.CriticalTime.Duration.Seconds()},
+		{Label: "entries", Value: float64(len(names))},
+	}
+	status := checks.Threshold(elapsed.Seconds(), f.WarningTime.Duration.Seconds(), f.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// client is a control connection. Transfers use passive mode, and TLS when
+// tlsConfig is set
+type client struct {
+	text      *textproto.Conn
+	host      net.IP
+	tlsConfig *tls.Config
+}
+
+// command sends a line and reads the reply, which must start with expectCode
+func (c *client) command(expectCode int, format string, args ...interface{}) (string, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+
+	_, message, err := c.text.ReadResponse(expectCode)
+	return message, err
+}
+
+// login sends USER, and PASS if the server asks for it
+func (c *client) login(username, password string) error {
+	id, err := c.text.Cmd("USER %s", username)
+	if err != nil {
+		return err
+	}
+	c.text.StartResponse(id)
+	code, message, err := c.text.ReadResponse(0)
+	c.text.EndResponse(id)
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 230:
+		return nil
+	case 331:
+		_, err := c.command(230, "PASS %s", password)
+		return err
+	}
+
+	return &textproto.Error{Code: code, Msg: message}
+}
+
+// passive asks the server for a data port and connects to it. The address in
+// the reply is ignored, as it is often wrong behind NAT
+func (c *client) passive(ctx context.Context) (net.Conn, error) {
+	var port int
+	if message, err := c.command(229, "EPSV"); err == nil {
+		fields := strings.Split(message, "|")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("could not read the reply to EPSV %q", message)
+		}
+		port, err = strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("could not read the reply to EPSV %q", message)
+		}
+	} else {
+		message, err := c.command(227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		open, end := strings.Index(message, "("), strings.Index(message, ")")
+		if open < 0 || end < open {
+			return nil, fmt.Errorf("could not read the reply to PASV %q", message)
+		}
+		var h1, h2, h3, h4, p1, p2 int
+		if _, err := fmt.Sscanf(message[open+1:end], "%d,%d,%d,%d,%d,%d", &h1, &h2, &h3, &h4, &p1, &p2); err != nil {
+			return nil, fmt.Errorf("could not read the reply to PASV %q", message)
+		}
+		port = p1<<8 | p2
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host.String(), strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if c.tlsConfig != nil {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+
+	return conn, nil
+}
+
+// transfer runs a command over a data connection. It sends upload if it is
+// set, and otherwise returns what the server sent
+func (c *client) transfer(ctx context.Context, upload []byte, format string, args ...interface{}) ([]byte, error) {
+	data, err := c.passive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+
+	if _, _, err := c.text.ReadResponse(1); err != nil {
+		return nil, err
+	}
+
+	var download []byte
+	if upload != nil {
+		_, err = data.Write(upload)
+	} else {
+		download, err = io.ReadAll(data)
+	}
+	// Closing marks the end of an upload
+	if closeErr := data.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return nil, err
+	}
+
+	return download, nil
+}
+
+// roundTrip uploads random bytes to path, downloads them again and deletes the
+// file. It returns how many bytes went each way
+func (c *client) roundTrip(ctx context.Context, path string) (int, error) {
+	sent := make([]byte, 4096)
+	if _, err := rand.Read(sent); err != nil {
+		return 0, err
+	}
+
+	if _, err := c.transfer(ctx, sent, "STOR %s", path); err != nil {
+		return 0, fmt.Errorf("upload: %s", err)
+	}
+	received, err := c.transfer(ctx, nil, "RETR %s", path)
+	if err != nil {
+		return 0, fmt.Errorf("download: %s", err)
+	}
+	if _, err := c.command(250, "DELE %s", path); err != nil {
+		return 0, fmt.Errorf("delete: %s", err)
+	}
+
+	sentHash, receivedHash := sha256.Sum256(sent), sha256.Sum256(received)
+	if !bytes.Equal(sentHash[:], receivedHash[:]) {
+		return 0, fmt.Errorf("sent %d bytes with SHA-256 %x, but got back %d bytes with %x", len(sent), sentHash, len(received), receivedHash)
+	}
+
+	return len(sent), nil
+}
+
+func init() {
+	checks.Add("ftp", func() gogios.Checker {
+		return &FTP{}
+	})
+}
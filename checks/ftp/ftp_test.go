@@ -0,0 +1,280 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// server is a stand-in FTP server with one directory held in memory. It
+// accepts anonymous logins and the password hunter2, and only allows uploads
+// to writable/
+type server struct {
+	config   *tls.Config
+	implicit bool
+	corrupt  bool
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// serve starts the server and returns its address and the path of a CA that
+// trusts it
+func serve(t *testing.T, s *server) (string, string) {
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(https.Close)
+	s.config = &tls.Config{Certificates: https.TLS.Certificates}
+	s.files = map[string][]byte{"readme.txt": []byte("hello\n"), "pub": nil}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	if s.implicit {
+		listener = tls.NewListener(listener, s.config)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.converse(conn)
+		}
+	}()
+
+	return listener.Addr().String(), caCert
+}
+
+func (s *server) converse(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 Stand-in FTP ready")
+
+	var user string
+	var data net.Listener
+	protected := false
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+
+	// accept takes the data connection for a transfer
+	accept := func() (net.Conn, bool) {
+		if data == nil {
+			text.PrintfLine("425 Use PASV or EPSV first")
+			return nil, false
+		}
+		conn, err := data.Accept()
+		data.Close()
+		data = nil
+		if err != nil {
+			text.PrintfLine("425 Can't open data connection")
+			return nil, false
+		}
+		text.PrintfLine("150 Opening data connection")
+		if protected {
+			conn = tls.Server(conn, s.config)
+		}
+		return conn, true
+	}
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "AUTH":
+			text.PrintfLine("234 Proceed with negotiation")
+			tlsConn := tls.Server(conn, s.config)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+		case "USER":
+			user = arg
+			if user == "anonymous" {
+				text.PrintfLine("230 Login successful")
+			} else {
+				text.PrintfLine("331 Please specify the password")
+			}
+		case "PASS":
+			if user == "gogios" && arg == "hunter2" {
+				text.PrintfLine("230 Login successful")
+			} else {
+				text.PrintfLine("530 Login incorrect")
+			}
+		case "PBSZ":
+			text.PrintfLine("200 PBSZ=0")
+		case "PROT":
+			protected = arg == "P"
+			text.PrintfLine("200 PROT now %s", arg)
+		case "TYPE":
+			text.PrintfLine("200 Switching to Binary mode")
+		case "EPSV":
+			// Only PASV is offered, to check the fallback
+			text.PrintfLine("502 EPSV not implemented")
+		case "PASV":
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				text.PrintfLine("421 Could not listen")
+				return
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			text.PrintfLine("227 Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+		case "NLST":
+			if arg != "" && arg != "/" {
+				text.PrintfLine("550 No such directory")
+				continue
+			}
+			conn, ok := accept()
+			if !ok {
+				continue
+			}
+			s.mu.Lock()
+			var names []string
+			for name := range s.files {
+				names = append(names, "/"+name)
+			}
+			s.mu.Unlock()
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(conn, "%s\r\n", name)
+			}
+			conn.Close()
+			text.PrintfLine("226 Directory send OK")
+		case "STOR":
+			if !strings.HasPrefix(arg, "writable/") {
+				text.PrintfLine("553 Could not create file")
+				continue
+			}
+			conn, ok := accept()
+			if !ok {
+				continue
+			}
+			content, _ := io.ReadAll(conn)
+			conn.Close()
+			if s.corrupt {
+				content = content[1:]
+			}
+			s.mu.Lock()
+			s.files[arg] = content
+			s.mu.Unlock()
+			text.PrintfLine("226 Transfer complete")
+		case "RETR":
+			s.mu.Lock()
+			content, ok := s.files[arg]
+			s.mu.Unlock()
+			if !ok {
+				text.PrintfLine("550 Failed to open file")
+				continue
+			}
+			conn, ok := accept()
+			if !ok {
+				continue
+			}
+			conn.Write(content)
+			conn.Close()
+			text.PrintfLine("226 Transfer complete")
+		case "DELE":
+			s.mu.Lock()
+			delete(s.files, arg)
+			s.mu.Unlock()
+			text.PrintfLine("250 Delete operation successful")
+		case "QUIT":
+			text.PrintfLine("221 Goodbye")
+			return
+		default:
+			text.PrintfLine("500 Unknown command")
+		}
+	}
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "ftp", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	plain := &server{}
+	addr, caCert := serve(t, plain)
+	host, port, _ := net.SplitHostPort(addr)
+	base := fmt.Sprintf(`"host": %q, "port": %s`, host, port)
+	login := base + `, "username": "gogios", "password": "hunter2"`
+	secure := login + fmt.Sprintf(`, "starttls": true, "ca_cert": %q`, caCert)
+
+	implicitAddr, _ := serve(t, &server{implicit: true})
+	implicitHost, implicitPort, _ := net.SplitHostPort(implicitAddr)
+	implicit := fmt.Sprintf(`"host": %q, "port": %s, "tls": true, "ca_cert": %q`, implicitHost, implicitPort, caCert)
+
+	corruptAddr, _ := serve(t, &server{corrupt: true})
+	corruptHost, corruptPort, _ := net.SplitHostPort(corruptAddr)
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"anonymous", `{` + base + `}`, gogios.StatusSuccess},
+		{"login", `{` + login + `}`, gogios.StatusSuccess},
+		{"wrong password", `{` + base + `, "username": "gogios", "password": "hunter3"}`, gogios.StatusFailed},
+		{"expected", `{` + login + `, "directory": "/", "expected": ["readme.txt", "pub"]}`, gogios.StatusSuccess},
+		{"missing entry", `{` + login + `, "expected": ["backup.tar"]}`, gogios.StatusFailed},
+		{"missing directory", `{` + login + `, "directory": "/nope"}`, gogios.StatusFailed},
+		{"round trip", `{` + login + `, "round_trip": "writable/gogios.tmp"}`, gogios.StatusSuccess},
+		{"read only", `{` + login + `, "round_trip": "gogios.tmp"}`, gogios.StatusFailed},
+		{"starttls", `{` + secure + `, "round_trip": "writable/gogios.tmp"}`, gogios.StatusSuccess},
+		{"untrusted", `{` + login + `, "starttls": true}`, gogios.StatusFailed},
+		{"implicit tls", `{` + implicit + `, "round_trip": "writable/gogios.tmp"}`, gogios.StatusSuccess},
+		{"corrupted", fmt.Sprintf(`{"host": %q, "port": %s, "round_trip": "writable/gogios.tmp"}`, corruptHost, corruptPort), gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+
+	if _, ok := plain.files["writable/gogios.tmp"]; ok {
+		t.Error("The round trip file was not deleted")
+	}
+}
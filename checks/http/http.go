@@ -0,0 +1,254 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// maxBody is how much of the response is read for the body assertions
+const maxBody = 1 << 20
+
+// HTTP requests a URL and checks the response
+type HTTP struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+
+	// Status codes that count as a success. Any 2xx or 3xx code by default
+	ExpectedStatus []int `json:"expected_status"`
+	// Regular expression that the body must match
+	BodyRegex string `json:"body_regex"`
+	// Values the JSON body must contain, by dotted path such as "data.items.0.id"
+	JSON map[string]interface{} `json:"json"`
+
+	// Redirects are followed unless follow_redirects is false
+	FollowRedirects *bool `json:"follow_redirects"`
+	MaxRedirects    int   `json:"max_redirects"`
+
+	checks.TLSOptions
+
+	// Basic auth, or a bearer token which takes precedence. The password and
+	// token are secret references, such as "env:HTTP_PASSWORD" or
+	// "file:/etc/gogios/token"
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	BearerToken string `json:"bearer_token"`
+
+	// Response times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	bodyRegex   *regexp.Regexp
+	client      *http.Client
+	password    string
+	bearerToken string
+}
+
+// Description returns a brief explanation of the check
+func (h *HTTP) Description() string {
+	return "Request a URL and check the status, body and response time"
+}
+
+// Init checks the options and builds the client
+func (h *HTTP) Init() error {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %s", h.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %q must use http or https", h.URL)
+	}
+
+	h.Method = strings.ToUpper(h.Method)
+	if h.Method == "" {
+		h.Method = http.MethodGet
+	}
+
+	if h.BodyRegex != "" {
+		h.bodyRegex, err = regexp.Compile(h.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid body_regex: %s", err)
+		}
+	}
+
+	h.password, err = helpers.ResolveSecret(h.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+	h.bearerToken, err = helpers.ResolveSecret(h.BearerToken)
+	if err != nil {
+		return fmt.Errorf("bearer_token: %s", err)
+	}
+
+	tlsConfig, err := h.TLSConfig("")
+	if err != nil {
+		return err
+	}
+
+	if h.MaxRedirects <= 0 {
+		h.MaxRedirects = 10
+	}
+	follow := h.FollowRedirects == nil || *h.FollowRedirects
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DisableKeepAlives = true
+
+	h.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !follow {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > h.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", h.MaxRedirects)
+			}
+			return nil
+		},
+	}
+
+	return nil
+}
+
+// Run makes the request and checks the response
+func (h *HTTP) Run(ctx context.Context) gogios.CheckResult {
+	req, err := http.NewRequestWithContext(ctx, h.Method, h.URL, strings.NewReader(h.Body))
+	if err != nil {
+		return checks.Failed(err)
+	}
+	for name, value := range h.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	if h.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.bearerToken)
+	} else if h.Username != "" {
+		req.SetBasicAuth(h.Username, h.password)
+	}
+
+	start := time.Now()
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	elapsed := time.Since(start)
+	if err != nil {
+		return checks.Failed(fmt.Errorf("reading the body failed: %s", err))
+	}
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: h.WarningTime.Duration.Seconds(), Critical: h.CriticalTime.Duration.Seconds()},
+		{Label: "size", Value: float64(len(body)), Unit: "B"},
+	}
+
+	output := fmt.Sprintf("%s %s in %s", resp.Proto, resp.Status, elapsed.Round(time.Millisecond))
+	problems := h.assert(resp, body)
+	if len(problems) > 0 {
+		return checks.Result(gogios.StatusFailed, output+"\n"+strings.Join(problems, "\n"), perfdata...)
+	}
+
+	status := checks.Threshold(elapsed.Seconds(), h.WarningTime.Duration.Seconds(), h.CriticalTime.Duration.Seconds())
+	if status != gogios.StatusSuccess {
+		output += ", which is too slow"
+	}
+
+	return checks.Result(status, output, perfdata...)
+}
+
+// assert returns every way the response differs from what was expected
+func (h *HTTP) assert(resp *http.Response, body []byte) []string {
+	var problems []string
+
+	if !h.statusOK(resp.StatusCode) {
+		problems = append(problems, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+
+	if h.bodyRegex != nil && !h.bodyRegex.Match(body) {
+		problems = append(problems, fmt.Sprintf("body does not match %q", h.BodyRegex))
+	}
+
+	if len(h.JSON) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return append(problems, fmt.Sprintf("body is not JSON: %s", err))
+		}
+
+		for path, want := range h.JSON {
+			got, err := lookup(doc, path)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", path, err))
+			} else if !reflect.DeepEqual(got, want) {
+				problems = append(problems, fmt.Sprintf("%s is %v, expected %v", path, got, want))
+			}
+		}
+	}
+
+	return problems
+}
+
+func (h *HTTP) statusOK(code int) bool {
+	if len(h.ExpectedStatus) == 0 {
+		return code >= 200 && code < 400
+	}
+
+	for _, expected := range h.ExpectedStatus {
+		if code == expected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup follows a dotted path through decoded JSON. Numbers in the path
+// index into arrays
+func lookup(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("no %q key", key)
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("no element %q in an array of %d", key, len(node))
+			}
+			current = node[i]
+		default:
+			return nil, errors.New("path goes past the end of the document")
+		}
+	}
+
+	return current, nil
+}
+
+func init() {
+	checks.Add("http", func() gogios.Checker {
+		return &HTTP{}
+	})
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok", "items": [{"id": 7}]}`))
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && user == "admin" && pass == "hunter2" {
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer token" {
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/status", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "http", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	return checker.Run(context.Background())
+}
+
+func TestRun(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	t.Setenv("GOGIOS_TEST_HTTP_PASSWORD", "hunter2")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token\n"), 0600); err != nil {
+		t.Fatalf("Could not write the token, got error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"ok", `{"url": "` + server.URL + `/status"}`, gogios.StatusSuccess},
+		{"missing page", `{"url": "` + server.URL + `/nothing"}`, gogios.StatusFailed},
+		{"expected status", `{"url": "` + server.URL + `/nothing", "expected_status": [404]}`, gogios.StatusSuccess},
+		{"body regex", `{"url": "` + server.URL + `/status", "body_regex": "\"status\": \"ok\""}`, gogios.StatusSuccess},
+		{"body regex mismatch", `{"url": "` + server.URL + `/status", "body_regex": "down"}`, gogios.StatusFailed},
+		{"json path", `{"url": "` + server.URL + `/status", "json": {"status": "ok", "items.0.id": 7}}`, gogios.StatusSuccess},
+		{"json path mismatch", `{"url": "` + server.URL + `/status", "json": {"items.0.id": 8}}`, gogios.StatusFailed},
+		{"json path missing", `{"url": "` + server.URL + `/status", "json": {"items.3.id": 7}}`, gogios.StatusFailed},
+		{"no auth", `{"url": "` + server.URL + `/private"}`, gogios.StatusFailed},
+		{"basic auth", `{"url": "` + server.URL + `/private", "username": "admin", "password": "hunter2"}`, gogios.StatusSuccess},
+		{"bearer token", `{"url": "` + server.URL + `/private", "bearer_token": "token"}`, gogios.StatusSuccess},
+		{"password from env", `{"url": "` + server.URL + `/private", "username": "admin", "password": "env:GOGIOS_TEST_HTTP_PASSWORD"}`, gogios.StatusSuccess},
+		{"token from file", `{"url": "` + server.URL + `/private", "bearer_token": "file:` + tokenFile + `"}`, gogios.StatusSuccess},
+		{"redirect", `{"url": "` + server.URL + `/moved", "expected_status": [200]}`, gogios.StatusSuccess},
+		{"no redirect", `{"url": "` + server.URL + `/moved", "expected_status": [200], "follow_redirects": false}`, gogios.StatusFailed},
+		{"slow warning", `{"url": "` + server.URL + `/slow", "warning_time": "10ms"}`, gogios.StatusWarning},
+		{"slow failure", `{"url": "` + server.URL + `/slow", "warning_time": "10ms", "critical_time": 0.02}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+		if result.ExitCode != gogios.StatusCode(result.Status) {
+			t.Errorf("%s: exit code %d does not match status %s", test.name, result.ExitCode, result.Status)
+		}
+	}
+}
+
+func TestPerfdata(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	result := run(t, `{"url": "`+server.URL+`/status", "warning_time": "1s", "critical_time": "2s"}`)
+	if len(result.Perfdata) != 2 {
+		t.Fatalf("Expected time and size perfdata, got: %v", result.Perfdata)
+	}
+	if result.Perfdata[1].Value != 38 {
+		t.Errorf("Expected a size of 38 bytes, got: %v", result.Perfdata[1].Value)
+	}
+	if !strings.Contains(result.FullOutput(), "| time=") || !strings.Contains(result.FullOutput(), "s;1;2 size=38B") {
+		t.Errorf("Perfdata was not added to the output, got: %s", result.FullOutput())
+	}
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{"url": "ftp://example.com"}`,
+		`{"url": "http://example.com", "body_regex": "("}`,
+		`{"url": "http://example.com", "ur": "typo"}`,
+		`{"url": "http://example.com", "warning_time": "soon"}`,
+		`{"url": "http://example.com", "username": "admin", "password": "env:GOGIOS_TEST_UNSET"}`,
+		`{"url": "http://example.com", "bearer_token": "file:/nonexistent"}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "http", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package imap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+var existsLine = regexp.MustCompile(`^\* (\d+) EXISTS`)
+
+// IMAP logs in to a mail server and opens a mailbox
+type IMAP struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // 143 by default, or 993 with tls
+
+	// Connect over TLS from the start, or upgrade with STARTTLS
+	TLS      bool `json:"tls"`
+	StartTLS bool `json:"starttls"`
+	checks.TLSOptions
+
+	// Regular expression that the greeting must match, such as "Dovecot"
+	Expect string `json:"expect"`
+
+	// The password is a secret reference, such as "env:IMAP_PASSWORD"
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Mailbox to open read only, INBOX by default
+	Mailbox string `json:"mailbox"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	password  string
+	expect    *regexp.Regexp
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (i *IMAP) Description() string {
+	return "Log in to an IMAP server and open a mailbox"
+}
+
+// Init checks the options
+func (i *IMAP) Init() error {
+	if i.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if i.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if i.TLS && i.StartTLS {
+		return fmt.Errorf("tls and starttls cannot both be set")
+	}
+	if i.Port == 0 {
+		i.Port = 143
+		if i.TLS {
+			i.Port = 993
+		}
+	}
+	i.address = net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+
+	if i.Mailbox == "" {
+		i.Mailbox = "INBOX"
+	}
+
+	var err error
+	i.password, err = helpers.ResolveSecret(i.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	if i.Expect != "" {
+		i.expect, err = regexp.Compile(i.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %s", err)
+		}
+	}
+
+	if i.TLS || i.StartTLS {
+		i.tlsConfig, err = i.TLSConfig(i.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run logs in and opens the mailbox
+func (i *IMAP) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	if i.TLS {
+		dialer := tls.Dialer{Config: i.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", i.address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", i.address)
+	}
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return fail("No greeting: %s", err)
+	}
+	output = append(output, greeting)
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fail("Server is not ready")
+	}
+	if i.expect != nil && !i.expect.MatchString(greeting) {
+		return fail("Greeting does not match %q", i.Expect)
+	}
+
+	session := &session{text: text}
+
+	if i.StartTLS {
+		if _, err := session.command("STARTTLS"); err != nil {
+			return fail("STARTTLS failed: %s", err)
+		}
+		tlsConn := tls.Client(conn, i.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail("TLS handshake failed: %s", err)
+		}
+		session.text = textproto.NewConn(tlsConn)
+		output = append(output, "Started TLS")
+	}
+
+	if _, err := session.command("LOGIN %s %s", quote(i.Username), quote(i.password)); err != nil {
+		return fail("Login as %s failed: %s", i.Username, err)
+	}
+	output = append(output, "Logged in as "+i.Username)
+
+	untagged, err := session.command("EXAMINE %s", quote(i.Mailbox))
+	if err != nil {
+		return fail("Could not open %s: %s", i.Mailbox, err)
+	}
+	messages := -1
+	for _, line := range untagged {
+		if match := existsLine.FindStringSubmatch(line); match != nil {
+			messages, _ = strconv.Atoi(match[1])
+		}
+	}
+	if messages < 0 {
+		return fail("Opened %s, but the server did not say how many messages it has", i.Mailbox)
+	}
+	output = append(output, fmt.Sprintf("%s has %d messages", i.Mailbox, messages))
+
+	session.command("LOGOUT")
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: i.WarningTime.Duration.Seconds(), Critical: i.CriticalTime.Duration.Seconds()},
+		{Label: "messages", Value: float64(messages)},
+	}
+	status := checks.Threshold(elapsed.Seconds(), i.WarningTime.Duration.Seconds(), i.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// session sends tagged commands
+type session struct {
+	text *textproto.Conn
+	tag  int
+}
+
+// command sends a command and reads up to its tagged reply, which must be OK.
+// It returns the untagged lines that came before it
+func (s *session) command(format string, args ...interface{}) ([]string, error) {
+	s.tag++
+	tag := "a" + strconv.Itoa(s.tag)
+	if err := s.text.PrintfLine(tag+" "+format, args...); err != nil {
+		return nil, err
+	}
+
+	var untagged []string
+	for {
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return untagged, err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+
+		reply := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(reply, "OK") {
+			return untagged, fmt.Errorf("%s", reply)
+		}
+		return untagged, nil
+	}
+}
+
+// quote makes a quoted string, escaping backslashes and quotes
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func init() {
+	checks.Add("imap", func() gogios.Checker {
+		return &IMAP{}
+	})
+}
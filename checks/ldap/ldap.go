@@ -0,0 +1,222 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+	"github.com/go-ldap/ldap/v3"
+)
+
+var scopes = map[string]int{
+	"base": ldap.ScopeBaseObject,
+	"one":  ldap.ScopeSingleLevel,
+	"sub":  ldap.ScopeWholeSubtree,
+}
+
+// LDAP binds to a directory server and runs a search
+type LDAP struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // 389 by default, or 636 with tls
+
+	// Connect over TLS from the start, or upgrade with StartTLS
+	TLS      bool `json:"tls"`
+	StartTLS bool `json:"starttls"`
+	checks.TLSOptions
+
+	// DN to bind as, anonymous if it is not set. The password is a secret
+	// reference, such as "env:LDAP_PASSWORD"
+	BindDN   string `json:"bind_dn"`
+	Password string `json:"password"`
+
+	BaseDN string `json:"base_dn"`
+	Filter string `json:"filter"` // (objectClass=*) by default
+	Scope  string `json:"scope"`  // base, one or sub, which is the default
+
+	// Fewest entries the search must return, 1 by default
+	MinEntries *int `json:"min_entries"`
+	// Attribute values that must be on at least one of the entries, such as
+	// {"mail": "admin@example.com"}
+	Expected map[string]string `json:"expected"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	url        string
+	password   string
+	minEntries int
+	tlsConfig  *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (l *LDAP) Description() string {
+	return "Bind to an LDAP server and check the results of a search"
+}
+
+// Init checks the options
+func (l *LDAP) Init() error {
+	if l.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if l.BaseDN == "" {
+		return fmt.Errorf("base_dn is required")
+	}
+	if l.TLS && l.StartTLS {
+		return fmt.Errorf("tls and starttls cannot both be set")
+	}
+
+	scheme := "ldap"
+	if l.TLS {
+		scheme = "ldaps"
+	}
+	if l.Port == 0 {
+		l.Port = 389
+		if l.TLS {
+			l.Port = 636
+		}
+	}
+	l.url = scheme + "://" + net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+
+	if l.Filter == "" {
+		l.Filter = "(objectClass=*)"
+	}
+	if _, err := ldap.CompileFilter(l.Filter); err != nil {
+		return fmt.Errorf("invalid filter: %s", err)
+	}
+	if l.Scope == "" {
+		l.Scope = "sub"
+	}
+	if _, ok := scopes[l.Scope]; !ok {
+		return fmt.Errorf("scope must be base, one or sub, not %q", l.Scope)
+	}
+
+	l.minEntries = 1
+	if l.MinEntries != nil {
+		l.minEntries = *l.MinEntries
+	}
+
+	var err error
+	if l.BindDN != "" {
+		l.password, err = helpers.ResolveSecret(l.Password)
+		if err != nil {
+			return fmt.Errorf("password: %s", err)
+		}
+		if l.password == "" {
+			return fmt.Errorf("password is required with bind_dn")
+		}
+	}
+
+	if l.TLS || l.StartTLS {
+		l.tlsConfig, err = l.TLSConfig(l.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run binds and searches
+func (l *LDAP) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	dialer := &net.Dialer{}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	conn, err := ldap.DialURL(l.url, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(l.tlsConfig))
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetTimeout(time.Until(deadline))
+	}
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	if l.StartTLS {
+		if err := conn.StartTLS(l.tlsConfig); err != nil {
+			return fail("StartTLS failed: %s", err)
+		}
+		output = append(output, "Started TLS")
+	}
+
+	if l.BindDN != "" {
+		if err := conn.Bind(l.BindDN, l.password); err != nil {
+			return fail("Bind as %s failed: %s", l.BindDN, err)
+		}
+		output = append(output, "Bound as "+l.BindDN)
+	}
+
+	var attributes []string
+	for attribute := range l.Expected {
+		attributes = append(attributes, attribute)
+	}
+	if attributes == nil {
+		// Only ask for the DNs
+		attributes = []string{"1.1"}
+	}
+
+	request := ldap.NewSearchRequest(l.BaseDN, scopes[l.Scope], ldap.NeverDerefAliases, 0, 0, false, l.Filter, attributes, nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		return fail("Search for %s under %s failed: %s", l.Filter, l.BaseDN, err)
+	}
+	entries := len(result.Entries)
+	output = append(output, fmt.Sprintf("Search for %s under %s found %d entries", l.Filter, l.BaseDN, entries))
+
+	if entries < l.minEntries {
+		return fail("Expected at least %d entries", l.minEntries)
+	}
+	for attribute, value := range l.Expected {
+		if !found(result.Entries, attribute, value) {
+			return fail("No entry has %s: %s", attribute, value)
+		}
+	}
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: l.WarningTime.Duration.Seconds(), Critical: l.CriticalTime.Duration.Seconds()},
+		{Label: "entries", Value: float64(entries)},
+	}
+	status := checks.Threshold(elapsed.Seconds(), l.WarningTime.Duration.Seconds(), l.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// found reports whether any of the entries has the attribute value
+func found(entries []*ldap.Entry, attribute, value string) bool {
+	for _, entry := range entries {
+		for _, v := range entry.GetEqualFoldAttributeValues(attribute) {
+			if v == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func init() {
+	checks.Add("ldap", func() gogios.Checker {
+		return &LDAP{}
+	})
+}
@@ -0,0 +1,204 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// people are the entries in the stand-in directory
+var people = []struct {
+	dn   string
+	uid  string
+	mail string
+}{
+	{"uid=alice,ou=people,dc=example,dc=com", "alice", "alice@example.com"},
+	{"uid=bob,ou=people,dc=example,dc=com", "bob", "bob@example.com"},
+}
+
+// serve starts a stand-in LDAP server that allows anonymous searches and binds
+// as cn=admin,dc=example,dc=com with the password hunter2. It returns its
+// address and the path of a CA that trusts it
+func serve(t *testing.T) (string, string) {
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(https.Close)
+	config := &tls.Config{Certificates: https.TLS.Certificates}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go converse(conn, config)
+		}
+	}()
+
+	return listener.Addr().String(), caCert
+}
+
+func converse(conn net.Conn, config *tls.Config) {
+	defer func() { conn.Close() }()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			name := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultSuccess)
+			if name != "cn=admin,dc=example,dc=com" || password != "hunter2" {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			reply(conn, id, result(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationExtendedRequest:
+			reply(conn, id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			tlsConn := tls.Server(conn, config)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+		case ldap.ApplicationSearchRequest:
+			base := request.Children[0].Value.(string)
+			if !strings.HasSuffix(base, "dc=example,dc=com") {
+				reply(conn, id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject))
+				continue
+			}
+			filter, _ := ldap.DecompileFilter(request.Children[6])
+			for _, person := range people {
+				if filter != "(objectClass=*)" && filter != "(uid="+person.uid+")" {
+					continue
+				}
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, person.dn, "DN"))
+				attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+				for name, value := range map[string]string{"uid": person.uid, "mail": person.mail} {
+					attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+					attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+					values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+					values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+					attribute.AppendChild(values)
+					attributes.AppendChild(attribute)
+				}
+				entry.AppendChild(attributes)
+				reply(conn, id, entry)
+			}
+			reply(conn, id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			return
+		}
+	}
+}
+
+// result makes an LDAPResult with the given code
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Code"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Message"))
+	return packet
+}
+
+func reply(conn net.Conn, id int64, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Message")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "ID"))
+	packet.AppendChild(op)
+	conn.Write(packet.Bytes())
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "ldap", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	addr, caCert := serve(t)
+	host, port, _ := net.SplitHostPort(addr)
+	base := fmt.Sprintf(`"host": %q, "port": %s, "base_dn": "ou=people,dc=example,dc=com"`, host, port)
+	bind := base + `, "bind_dn": "cn=admin,dc=example,dc=com", "password": "hunter2"`
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"anonymous", `{` + base + `}`, gogios.StatusSuccess},
+		{"bind", `{` + bind + `}`, gogios.StatusSuccess},
+		{"wrong password", `{` + base + `, "bind_dn": "cn=admin,dc=example,dc=com", "password": "hunter3"}`, gogios.StatusFailed},
+		{"starttls", `{` + bind + `, "starttls": true, "ca_cert": "` + caCert + `"}`, gogios.StatusSuccess},
+		{"untrusted", `{` + bind + `, "starttls": true}`, gogios.StatusFailed},
+		{"min entries", `{` + base + `, "min_entries": 2}`, gogios.StatusSuccess},
+		{"too few entries", `{` + base + `, "filter": "(uid=alice)", "min_entries": 2}`, gogios.StatusFailed},
+		{"no entries", `{` + base + `, "filter": "(uid=carol)"}`, gogios.StatusFailed},
+		{"none expected", `{` + base + `, "filter": "(uid=carol)", "min_entries": 0}`, gogios.StatusSuccess},
+		{"attribute", `{` + base + `, "filter": "(uid=bob)", "expected": {"mail": "bob@example.com"}}`, gogios.StatusSuccess},
+		{"wrong attribute", `{` + base + `, "filter": "(uid=bob)", "expected": {"mail": "alice@example.com"}}`, gogios.StatusFailed},
+		{"missing base", `{"host": "` + host + `", "port": ` + port + `, "base_dn": "dc=example,dc=org"}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{"base_dn": "dc=example,dc=com"}`,
+		`{"host": "example.com"}`,
+		`{"host": "example.com", "base_dn": "dc=example,dc=com", "filter": "uid=alice"}`,
+		`{"host": "example.com", "base_dn": "dc=example,dc=com", "scope": "children"}`,
+		`{"host": "example.com", "base_dn": "dc=example,dc=com", "bind_dn": "cn=admin,dc=example,dc=com"}`,
+		`{"host": "example.com", "base_dn": "dc=example,dc=com", "tls": true, "starttls": true}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "ldap", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/checks/sqlquery"
+	"github.com/bkasin/gogios/helpers"
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// MySQL logs in to a MySQL or MariaDB server and optionally runs a query
+type MySQL struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"` // A secret reference, such as "env:MYSQL_PASSWORD"
+	Database string `json:"database"`
+
+	// Connect over TLS and verify the certificate
+	TLS bool `json:"tls"`
+	checks.TLSOptions
+
+	sqlquery.Query
+
+	config    *driver.Config
+	connector sqldriver.Connector
+}
+
+// Description returns a brief explanation of the check
+func (m *MySQL) Description() string {
+	return "Log in to MySQL and check the result of a query"
+}
+
+// Init checks the options and builds the connection settings and connector
+func (m *MySQL) Init() error {
+	if m.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if m.User == "" {
+		return fmt.Errorf("user is required")
+	}
+	if m.Port == 0 {
+		m.Port = 3306
+	}
+
+	password, err := helpers.ResolveSecret(m.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	m.config = driver.NewConfig()
+	m.config.Net = "tcp"
+	m.config.Addr = net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	m.config.User = m.User
+	m.config.Passwd = password
+	m.config.DBName = m.Database
+
+	if m.TLS {
+		m.config.TLS, err = m.TLSConfig(m.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.connector, err = driver.NewConnector(m.config)
+	return err
+}
+
+// Run logs in and runs the query
+func (m *MySQL) Run(ctx context.Context) gogios.CheckResult {
+	return m.Query.Run(ctx, m.connector, "MySQL at "+m.config.Addr)
+}
+
+func init() {
+	checks.Add("mysql", func() gogios.Checker {
+		return &MySQL{}
+	})
+}
@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/checks/sqlquery/sqlquerytest"
+)
+
+func create(t *testing.T, options string) *MySQL {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "mysql", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	return checker.(*MySQL)
+}
+
+func TestInit(t *testing.T) {
+	t.Setenv("GOGIOS_MYSQL_PASSWORD", "p@ss word/")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("from a file\n"), 0600); err != nil {
+		t.Fatalf("Could not write the password, got error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		dsn     string
+	}{
+		{"defaults", `{"host": "db.example.com", "user": "gogios"}`, "gogios@tcp(db.example.com:3306)/"},
+		{"env password", `{"host": "db.example.com", "port": 3307, "user": "gogios", "password": "env:GOGIOS_MYSQL_PASSWORD", "database": "app"}`, "gogios:p@ss word/@tcp(db.example.com:3307)/app"},
+		{"file password", `{"host": "db.example.com", "user": "gogios", "password": "file:` + passwordFile + `"}`, "gogios:from a file@tcp(db.example.com:3306)/"},
+		{"ipv6", `{"host": "::1", "user": "gogios", "password": "hunter2"}`, "gogios:hunter2@tcp([::1]:3306)/"},
+	}
+
+	for _, test := range tests {
+		m := create(t, test.options)
+		if dsn := m.config.FormatDSN(); dsn != test.dsn {
+			t.Errorf("%s: wrong connection string\nwant: %s\n got: %s", test.name, test.dsn, dsn)
+		}
+		if m.config.TLS != nil {
+			t.Errorf("%s: TLS should be off by default", test.name)
+		}
+		if m.connector == nil {
+			t.Errorf("%s: no connector was built", test.name)
+		}
+	}
+
+	bad := []string{
+		`{"user": "gogios"}`,
+		`{"host": "db.example.com"}`,
+		`{"host": "db.example.com", "user": "gogios", "password": "env:GOGIOS_UNSET_SECRET"}`,
+		`{"host": "db.example.com", "user": "gogios", "tls": true, "ca_cert": "/nonexistent/ca.pem"}`,
+	}
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "mysql", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	m := create(t, fmt.Sprintf(`{"host": "127.0.0.1", "user": "gogios", "tls": true, "ca_cert": %q}`, caCert))
+	if m.config.TLS == nil || m.config.TLS.ServerName != "127.0.0.1" || m.config.TLS.InsecureSkipVerify {
+		t.Fatalf("Expected TLS verifying 127.0.0.1, got %+v", m.config.TLS)
+	}
+	// The CA given is the only one trusted
+	if _, err := srv.Certificate().Verify(x509.VerifyOptions{Roots: m.config.TLS.RootCAs, DNSName: "127.0.0.1"}); err != nil {
+		t.Errorf("The server certificate should be trusted, got error: %s", err)
+	}
+
+	m = create(t, `{"host": "10.0.0.5", "user": "gogios", "tls": true, "server_name": "db.example.com", "insecure_skip_verify": true}`)
+	if m.config.TLS.ServerName != "db.example.com" || !m.config.TLS.InsecureSkipVerify {
+		t.Errorf("Expected server_name and insecure_skip_verify to be used, got %+v", m.config.TLS)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   string
+		connector *sqlquerytest.Connector
+		status    string
+	}{
+		{"login", ``, &sqlquerytest.Connector{}, gogios.StatusSuccess},
+		{"login failed", ``, &sqlquerytest.Connector{Err: errors.New("access denied")}, gogios.StatusFailed},
+		{"expected", `, "query": "SELECT COUNT(*) FROM users", "expected": "42"`, &sqlquerytest.Connector{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(42)}}}, gogios.StatusSuccess},
+		{"unexpected", `, "query": "SELECT COUNT(*) FROM users", "expected": "0"`, &sqlquerytest.Connector{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(42)}}}, gogios.StatusFailed},
+		{"value threshold", `, "query": "SELECT lag", "warning_value": 10, "critical_value": 60`, &sqlquerytest.Connector{Columns: []string{"lag"}, Rows: [][]driver.Value{{int64(30)}}}, gogios.StatusWarning},
+		{"slow", `, "query": "SELECT 1", "warning_time": "20ms", "critical_time": "1s"`, &sqlquerytest.Connector{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}, Delay: 30 * time.Millisecond}, gogios.StatusWarning},
+		{"too slow", `, "query": "SELECT 1", "critical_time": "20ms"`, &sqlquerytest.Connector{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}, Delay: 30 * time.Millisecond}, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		m := create(t, `{"host": "db.example.com", "user": "gogios"`+test.options+`}`)
+		m.connector = test.connector
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		result := m.Run(ctx)
+		cancel()
+
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+		if !strings.Contains(result.Output, "MySQL at db.example.com:3306") {
+			t.Errorf("%s: expected the server in the output, got: %s", test.name, result.Output)
+		}
+		if test.connector.Query != m.Query.Query {
+			t.Errorf("%s: expected %q to be run, got %q", test.name, m.Query.Query, test.connector.Query)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Protocol numbers for parsing replies
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// Ping sends ICMP echo requests to a host and rates the packet loss and round
+// trip times. It uses unprivileged datagram sockets where the system allows
+// them, which on Linux is set by net.ipv4.ping_group_range, and raw sockets
+// otherwise
+type Ping struct {
+	Host     string           `json:"host"`
+	Count    int              `json:"count"`    // Probes to send, 5 by default
+	Interval helpers.Duration `json:"interval"` // Between probes, 1s by default
+	// How long to wait for a reply after the last probe, 1s by default
+	Timeout helpers.Duration `json:"timeout"`
+
+	// Packet loss percentages that make the check a warning or a failure.
+	// critical_loss is 100 by default, so a host that never replies fails
+	WarningLoss  float64 `json:"warning_loss"`
+	CriticalLoss float64 `json:"critical_loss"`
+
+	// Average and maximum round trip times that make the check a warning or
+	// a failure
+	WarningRTT     helpers.Duration `json:"warning_rtt"`
+	CriticalRTT    helpers.Duration `json:"critical_rtt"`
+	WarningMaxRTT  helpers.Duration `json:"warning_max_rtt"`
+	CriticalMaxRTT helpers.Duration `json:"critical_max_rtt"`
+}
+
+// Description returns a brief explanation of the check
+func (p *Ping) Description() string {
+	return "Ping a host and check the packet loss and round trip times"
+}
+
+// Init checks the options
+func (p *Ping) Init() error {
+	if p.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if p.Count < 0 || p.Count > 100 {
+		return fmt.Errorf("count must be between 1 and 100 (0 for the default of 5), got %d", p.Count)
+	}
+	if p.Count == 0 {
+		p.Count = 5
+	}
+	if p.Interval.Duration <= 0 {
+		p.Interval.Duration = time.Second
+	}
+	if p.Timeout.Duration <= 0 {
+		p.Timeout.Duration = time.Second
+	}
+	if p.CriticalLoss <= 0 {
+		p.CriticalLoss = 100
+	}
+
+	return nil
+}
+
+// Run sends the probes and waits for the replies
+func (p *Ping) Run(ctx context.Context) gogios.CheckResult {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, p.Host)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	ip := addrs[0].IP
+
+	conn, dst, protocol, err := listen(ip)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	// Replies are matched on the payload, as datagram sockets replace the ID
+	// with their own and raw sockets see every reply on the host
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return checks.Failed(err)
+	}
+
+	echoType := icmp.Type(ipv4.ICMPTypeEcho)
+	if protocol == protocolIPv6ICMP {
+		echoType = ipv6.ICMPTypeEchoRequest
+	}
+
+	sent := make([]time.Time, p.Count)
+	var rtts []time.Duration
+	buffer := make([]byte, 1500)
+	next := time.Now()
+
+	for seq := 0; seq < p.Count; seq++ {
+		message := icmp.Message{Type: echoType, Body: &icmp.Echo{ID: int(token[0])<<8 | int(token[1]), Seq: seq, Data: token}}
+		packet, err := message.Marshal(nil)
+		if err != nil {
+			return checks.Failed(err)
+		}
+		sent[seq] = time.Now()
+		if _, err := conn.WriteTo(packet, dst); err != nil {
+			return checks.Failed(fmt.Errorf("could not send to %s: %s", ip, err))
+		}
+
+		next = next.Add(p.Interval.Duration)
+		if seq == p.Count-1 {
+			next = time.Now().Add(p.Timeout.Duration)
+		}
+
+		// Take replies until it is time for the next probe, or after the last
+		// one until every probe has a reply
+		conn.SetReadDeadline(next)
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				break
+			}
+			reply, err := icmp.ParseMessage(protocol, buffer[:n])
+			if err != nil {
+				continue
+			}
+			echo, ok := reply.Body.(*icmp.Echo)
+			if !ok || (reply.Type != ipv4.ICMPTypeEchoReply && reply.Type != ipv6.ICMPTypeEchoReply) {
+				continue
+			}
+			if !bytes.Equal(echo.Data, token) || echo.Seq < 0 || echo.Seq > seq || sent[echo.Seq].IsZero() {
+				continue
+			}
+			rtts = append(rtts, time.Since(sent[echo.Seq]))
+			// Count each probe once, even if the reply is duplicated
+			sent[echo.Seq] = time.Time{}
+			if len(rtts) == p.Count {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			return checks.Failed(fmt.Errorf("timed out pinging %s after %d probes", ip, seq+1))
+		}
+	}
+
+	loss := float64(p.Count-len(rtts)) / float64(p.Count) * 100
+	perfdata := []gogios.Perfdata{
+		{Label: "loss", Value: loss, Unit: "%", Warning: p.WarningLoss, Critical: p.CriticalLoss},
+	}
+	if len(rtts) == 0 {
+		return checks.Result(gogios.StatusFailed, fmt.Sprintf("No replies from %s to %d probes", ip, p.Count), perfdata...)
+	}
+
+	var total, slowest time.Duration
+	fastest := rtts[0]
+	for _, rtt := range rtts {
+		total += rtt
+		if rtt > slowest {
+			slowest = rtt
+		}
+		if rtt < fastest {
+			fastest = rtt
+		}
+	}
+	avg := total / time.Duration(len(rtts))
+
+	perfdata = append(perfdata,
+		gogios.Perfdata{Label: "rtt_avg", Value: avg.Seconds(), Unit: "s", Warning: p.WarningRTT.Duration.Seconds(), Critical: p.CriticalRTT.Duration.Seconds()},
+		gogios.Perfdata{Label: "rtt_max", Value: slowest.Seconds(), Unit: "s", Warning: p.WarningMaxRTT.Duration.Seconds(), Critical: p.CriticalMaxRTT.Duration.Seconds()},
+		gogios.Perfdata{Label: "rtt_min", Value: fastest.Seconds(), Unit: "s"},
+	)
+	output := fmt.Sprintf("%s: %d of %d replies, %.0f%% packet loss, round trip min/avg/max %s/%s/%s",
+		ip, len(rtts), p.Count, loss, round(fastest), round(avg), round(slowest))
+	status := checks.Worst(
+		checks.Threshold(loss, p.WarningLoss, p.CriticalLoss),
+		checks.Threshold(avg.Seconds(), p.WarningRTT.Duration.Seconds(), p.CriticalRTT.Duration.Seconds()),
+		checks.Threshold(slowest.Seconds(), p.WarningMaxRTT.Duration.Seconds(), p.CriticalMaxRTT.Duration.Seconds()),
+	)
+
+	return checks.Result(status, output, perfdata...)
+}
+
+// listen opens an ICMP socket for the address family of ip, preferring an
+// unprivileged datagram socket. It returns the socket, the address to send to
+// and the protocol number of the replies
+func listen(ip net.IP) (*icmp.PacketConn, net.Addr, int, error) {
+	datagram, raw, address, protocol := "udp4", "ip4:icmp", "0.0.0.0", protocolICMP
+	if ip.To4() == nil {
+		datagram, raw, address, protocol = "udp6", "ip6:ipv6-icmp", "::", protocolIPv6ICMP
+	}
+
+	conn, err := icmp.ListenPacket(datagram, address)
+	if err == nil {
+		return conn, &net.UDPAddr{IP: ip}, protocol, nil
+	}
+
+	conn, rawErr := icmp.ListenPacket(raw, address)
+	if rawErr != nil {
+		return nil, nil, 0, fmt.Errorf("could not open an ICMP socket, datagram sockets failed with %q and raw sockets with %q", err, rawErr)
+	}
+
+	return conn, &net.IPAddr{IP: ip}, protocol, nil
+}
+
+// round shortens a round trip time for the output
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+
+	return d.Round(10 * time.Microsecond)
+}
+
+func init() {
+	checks.Add("ping", func() gogios.Checker {
+		return &Ping{}
+	})
+}
@@ -0,0 +1,71 @@
+package ping
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "ping", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	conn, _, _, err := listen(net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Skipf("ICMP sockets are not allowed here: %s", err)
+	}
+	conn.Close()
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"loopback", `{"host": "127.0.0.1", "count": 3, "interval": "10ms"}`, gogios.StatusSuccess},
+		{"thresholds", `{"host": "127.0.0.1", "count": 3, "interval": "10ms", "warning_rtt": "1s", "critical_max_rtt": "2s", "warning_loss": 50}`, gogios.StatusSuccess},
+		{"slow", `{"host": "127.0.0.1", "count": 2, "interval": "10ms", "warning_rtt": "1ns"}`, gogios.StatusWarning},
+		{"too slow", `{"host": "127.0.0.1", "count": 2, "interval": "10ms", "warning_rtt": "1ns", "critical_max_rtt": "1ns"}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+
+	result := run(t, `{"host": "127.0.0.1", "count": 4, "interval": "10ms"}`)
+	if len(result.Perfdata) != 4 || result.Perfdata[0].Label != "loss" || result.Perfdata[0].Value != 0 {
+		t.Errorf("Expected no packet loss, got: %+v", result.Perfdata)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{}`,
+		`{"host": "127.0.0.1", "count": -1}`,
+		`{"host": "127.0.0.1", "count": 1000}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "ping", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
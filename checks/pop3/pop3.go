@@ -0,0 +1,201 @@
+package pop3
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// POP3 logs in to a mail server and asks how many messages are waiting
+type POP3 struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // 110 by default, or 995 with tls
+
+	// Connect over TLS from the start, or upgrade with STLS
+	TLS      bool `json:"tls"`
+	StartTLS bool `json:"starttls"`
+	checks.TLSOptions
+
+	// Regular expression that the greeting must match, such as "Dovecot"
+	Expect string `json:"expect"`
+
+	// The password is a secret reference, such as "env:POP3_PASSWORD"
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	password  string
+	expect    *regexp.Regexp
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (p *POP3) Description() string {
+	return "Log in to a POP3 server and check the mailbox"
+}
+
+// Init checks the options
+func (p *POP3) Init() error {
+	if p.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if p.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if p.TLS && p.StartTLS {
+		return fmt.Errorf("tls and starttls cannot both be set")
+	}
+	if p.Port == 0 {
+		p.Port = 110
+		if p.TLS {
+			p.Port = 995
+		}
+	}
+	p.address = net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+
+	var err error
+	p.password, err = helpers.ResolveSecret(p.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	if p.Expect != "" {
+		p.expect, err = regexp.Compile(p.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %s", err)
+		}
+	}
+
+	if p.TLS || p.StartTLS {
+		p.tlsConfig, err = p.TLSConfig(p.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run logs in and runs STAT
+func (p *POP3) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	if p.TLS {
+		dialer := tls.Dialer{Config: p.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", p.address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", p.address)
+	}
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	text := textproto.NewConn(conn)
+	greeting, err := reply(text)
+	if err != nil {
+		return fail("Bad greeting: %s", err)
+	}
+	output = append(output, "+OK "+greeting)
+	if p.expect != nil && !p.expect.MatchString(greeting) {
+		return fail("Greeting does not match %q", p.Expect)
+	}
+
+	if p.StartTLS {
+		if _, err := command(text, "STLS"); err != nil {
+			return fail("STLS failed: %s", err)
+		}
+		tlsConn := tls.Client(conn, p.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail("TLS handshake failed: %s", err)
+		}
+		text = textproto.NewConn(tlsConn)
+		output = append(output, "Started TLS")
+	}
+
+	if _, err := command(text, "USER %s", p.Username); err != nil {
+		return fail("Login as %s failed: %s", p.Username, err)
+	}
+	if _, err := command(text, "PASS %s", p.password); err != nil {
+		return fail("Login as %s failed: %s", p.Username, err)
+	}
+	output = append(output, "Logged in as "+p.Username)
+
+	stat, err := command(text, "STAT")
+	if err != nil {
+		return fail("STAT failed: %s", err)
+	}
+	var messages, size int
+	if _, err := fmt.Sscanf(stat, "%d %d", &messages, &size); err != nil {
+		return fail("Could not read the reply to STAT %q", stat)
+	}
+	output = append(output, fmt.Sprintf("%d messages, %d bytes", messages, size))
+
+	command(text, "QUIT")
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: p.WarningTime.Duration.Seconds(), Critical: p.CriticalTime.Duration.Seconds()},
+		{Label: "messages", Value: float64(messages)},
+		{Label: "size", Value: float64(size), Unit: "B"},
+	}
+	status := checks.Threshold(elapsed.Seconds(), p.WarningTime.Duration.Seconds(), p.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// command sends a line and reads the reply, which must be +OK
+func command(text *textproto.Conn, format string, args ...interface{}) (string, error) {
+	if err := text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+
+	return reply(text)
+}
+
+// reply reads a one line reply and returns what follows +OK
+func reply(text *textproto.Conn) (string, error) {
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("%s", line)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+func init() {
+	checks.Add("pop3", func() gogios.Checker {
+		return &POP3{}
+	})
+}
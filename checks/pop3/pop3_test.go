@@ -0,0 +1,140 @@
+package pop3
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// serve starts a stand-in POP3 server that offers STLS and accepts the
+// password hunter2. It returns its address and the path of a CA that trusts it
+func serve(t *testing.T) (string, string) {
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(https.Close)
+	config := &tls.Config{Certificates: https.TLS.Certificates}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go converse(conn, config)
+		}
+	}()
+
+	return listener.Addr().String(), caCert
+}
+
+func converse(conn net.Conn, config *tls.Config) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("+OK Stand-in POP3 ready")
+
+	var user string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "STLS":
+			text.PrintfLine("+OK Begin TLS negotiation")
+			tlsConn := tls.Server(conn, config)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+		case "USER":
+			user = arg
+			text.PrintfLine("+OK")
+		case "PASS":
+			if user == "gogios" && arg == "hunter2" {
+				text.PrintfLine("+OK Logged in.")
+			} else {
+				text.PrintfLine("-ERR [AUTH] Authentication failed.")
+			}
+		case "STAT":
+			text.PrintfLine("+OK 2 3072")
+		case "QUIT":
+			text.PrintfLine("+OK Logging out.")
+			return
+		default:
+			text.PrintfLine("-ERR Unknown command")
+		}
+	}
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "pop3", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	addr, caCert := serve(t)
+	host, port, _ := net.SplitHostPort(addr)
+	base := fmt.Sprintf(`"host": %q, "port": %s, "username": "gogios"`, host, port)
+	login := base + `, "password": "hunter2"`
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"login", `{` + login + `}`, gogios.StatusSuccess},
+		{"greeting", `{` + login + `, "expect": "Stand-in"}`, gogios.StatusSuccess},
+		{"wrong greeting", `{` + login + `, "expect": "Dovecot"}`, gogios.StatusFailed},
+		{"wrong password", `{` + base + `, "password": "hunter3"}`, gogios.StatusFailed},
+		{"starttls", `{` + login + `, "starttls": true, "ca_cert": "` + caCert + `"}`, gogios.StatusSuccess},
+		{"untrusted", `{` + login + `, "starttls": true}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+
+	result := run(t, `{`+login+`}`)
+	if !strings.HasSuffix(result.Output, "2 messages, 3072 bytes") || result.Perfdata[2].Value != 3072 {
+		t.Errorf("Expected 2 messages, got: %s", result.Output)
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/checks/sqlquery"
+	"github.com/bkasin/gogios/helpers"
+	"github.com/lib/pq"
+)
+
+// Postgres logs in to a PostgreSQL server and optionally runs a query
+type Postgres struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"` // A secret reference, such as "env:PGPASSWORD"
+	Database string `json:"database"`
+
+	// disable, require, verify-ca or verify-full. require by default
+	SSLMode string `json:"sslmode"`
+	CACert  string `json:"ca_cert"` // PEM file of the CA to verify against
+
+	sqlquery.Query
+
+	address   string
+	dsn       string
+	connector driver.Connector
+}
+
+// Description returns a brief explanation of the check
+func (p *Postgres) Description() string {
+	return "Log in to PostgreSQL and check the result of a query"
+}
+
+// Init checks the options and builds the connection string and connector
+func (p *Postgres) Init() error {
+	if p.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if p.User == "" {
+		return fmt.Errorf("user is required")
+	}
+	if p.Port == 0 {
+		p.Port = 5432
+	}
+	if p.Database == "" {
+		p.Database = "postgres"
+	}
+
+	switch p.SSLMode {
+	case "":
+		p.SSLMode = "require"
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("sslmode must be disable, require, verify-ca or verify-full, not %q", p.SSLMode)
+	}
+
+	password, err := helpers.ResolveSecret(p.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", p.SSLMode)
+	if p.CACert != "" {
+		query.Set("sslrootcert", p.CACert)
+	}
+
+	p.address = net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, password),
+		Host:     p.address,
+		Path:     "/" + p.Database,
+		RawQuery: query.Encode(),
+	}
+	p.dsn = dsn.String()
+
+	p.connector, err = pq.NewConnector(p.dsn)
+	return err
+}
+
+// Run logs in and runs the query
+func (p *Postgres) Run(ctx context.Context) gogios.CheckResult {
+	return p.Query.Run(ctx, p.connector, "PostgreSQL at "+p.address)
+}
+
+func init() {
+	checks.Add("postgres", func() gogios.Checker {
+		return &Postgres{}
+	})
+}
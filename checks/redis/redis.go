@@ -0,0 +1,214 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// Redis logs in to a Redis server and runs a command, PING by default
+type Redis struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"` // For servers with ACLs
+	Password string `json:"password"` // A secret reference, such as "env:REDIS_PASSWORD"
+	DB       int    `json:"db"`
+
+	// Connect over TLS and verify the certificate
+	TLS bool `json:"tls"`
+	checks.TLSOptions
+
+	// Command and its arguments, such as ["LLEN", "queue"]
+	Command []string `json:"command"`
+	// The reply must equal this. PONG when the command is PING
+	Expected string `json:"expected"`
+	// A reply above these, such as the length of a queue, makes the check a
+	// warning or a failure
+	WarningValue  float64 `json:"warning_value"`
+	CriticalValue float64 `json:"critical_value"`
+
+	// Command times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	password  string
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (r *Redis) Description() string {
+	return "Log in to Redis and check the reply to a command"
+}
+
+// Init checks the options
+func (r *Redis) Init() error {
+	if r.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if r.Port == 0 {
+		r.Port = 6379
+	}
+	r.address = net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+
+	var err error
+	r.password, err = helpers.ResolveSecret(r.Password)
+	if err != nil {
+		return fmt.Errorf("password: %s", err)
+	}
+
+	if len(r.Command) == 0 {
+		r.Command = []string{"PING"}
+	}
+	if r.Expected == "" && len(r.Command) == 1 && strings.EqualFold(r.Command[0], "PING") {
+		r.Expected = "PONG"
+	}
+
+	if r.TLS {
+		r.tlsConfig, err = r.TLSConfig(r.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run logs in, selects the database and runs the command
+func (r *Redis) Run(ctx context.Context) gogios.CheckResult {
+	var conn net.Conn
+	var err error
+	if r.tlsConfig != nil {
+		dialer := tls.Dialer{Config: r.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", r.address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", r.address)
+	}
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+
+	if r.password != "" {
+		auth := []string{"AUTH", r.password}
+		if r.Username != "" {
+			auth = []string{"AUTH", r.Username, r.password}
+		}
+		if _, err := do(conn, reader, auth...); err != nil {
+			return checks.Failed(fmt.Errorf("login to %s failed: %s", r.address, err))
+		}
+	}
+
+	if r.DB != 0 {
+		if _, err := do(conn, reader, "SELECT", strconv.Itoa(r.DB)); err != nil {
+			return checks.Failed(fmt.Errorf("could not select database %d: %s", r.DB, err))
+		}
+	}
+
+	start := time.Now()
+	reply, err := do(conn, reader, r.Command...)
+	elapsed := time.Since(start)
+	if err != nil {
+		return checks.Failed(fmt.Errorf("%s failed: %s", r.Command[0], err))
+	}
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: r.WarningTime.Duration.Seconds(), Critical: r.CriticalTime.Duration.Seconds()},
+	}
+	output := fmt.Sprintf("%s on %s replied in %s\n%s", strings.ToUpper(r.Command[0]), r.address, elapsed.Round(time.Millisecond), reply)
+
+	status, problem := checks.Compare(reply, r.Expected, r.WarningValue, r.CriticalValue)
+	if problem != "" {
+		output += "\n" + problem
+	}
+	status = checks.Worst(status, checks.Threshold(elapsed.Seconds(), r.WarningTime.Duration.Seconds(), r.CriticalTime.Duration.Seconds()))
+
+	return checks.Result(status, output, perfdata...)
+}
+
+// do sends a command and reads its reply. Error replies are returned as errors
+func do(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return "", err
+	}
+
+	return readReply(reader)
+}
+
+// readReply reads one RESP reply. Arrays are returned one element per line
+func readReply(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", errors.New(line[1:])
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+		if length < 0 {
+			return "(nil)", nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return "", err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid array length %q", line[1:])
+		}
+		elements := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			element, err := readReply(reader)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, element)
+		}
+		return strings.Join(elements, "\n"), nil
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func init() {
+	checks.Add("redis", func() gogios.Checker {
+		return &Redis{}
+	})
+}
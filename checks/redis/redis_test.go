@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// serve answers a few commands, and requires the password hunter2 if auth is
+// set
+func serve(t *testing.T, auth bool) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				loggedIn := !auth
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					var reply string
+					switch strings.ToUpper(args[0]) {
+					case "AUTH":
+						loggedIn = args[len(args)-1] == "hunter2"
+						reply = "+OK\r\n"
+						if !loggedIn {
+							reply = "-WRONGPASS invalid password\r\n"
+						}
+					case "SELECT":
+						reply = "+OK\r\n"
+					case "PING":
+						reply = "+PONG\r\n"
+					case "LLEN":
+						reply = ":42\r\n"
+					case "GET":
+						reply = "$-1\r\n"
+					case "LRANGE":
+						reply = "*2\r\n$3\r\none\r\n$3\r\ntwo\r\n"
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					if !loggedIn {
+						reply = "-NOAUTH Authentication required.\r\n"
+					}
+					io.WriteString(conn, reply)
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	reply, err := readReply(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(reply, "\n"), nil
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "redis", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	open, _ := net.ResolveTCPAddr("tcp", serve(t, false))
+	locked, _ := net.ResolveTCPAddr("tcp", serve(t, true))
+	server := func(addr *net.TCPAddr) string {
+		return fmt.Sprintf(`"host": %q, "port": %d`, addr.IP.String(), addr.Port)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"ping", `{` + server(open) + `}`, gogios.StatusSuccess},
+		{"no password", `{` + server(locked) + `}`, gogios.StatusFailed},
+		{"password", `{` + server(locked) + `, "password": "hunter2", "db": 2}`, gogios.StatusSuccess},
+		{"wrong password", `{` + server(locked) + `, "username": "gogios", "password": "hunter3"}`, gogios.StatusFailed},
+		{"value", `{` + server(open) + `, "command": ["LLEN", "queue"], "expected": "42"}`, gogios.StatusSuccess},
+		{"wrong value", `{` + server(open) + `, "command": ["LLEN", "queue"], "expected": "0"}`, gogios.StatusFailed},
+		{"warning value", `{` + server(open) + `, "command": ["LLEN", "queue"], "warning_value": 10, "critical_value": 100}`, gogios.StatusWarning},
+		{"critical value", `{` + server(open) + `, "command": ["LLEN", "queue"], "critical_value": 40}`, gogios.StatusFailed},
+		{"nil", `{` + server(open) + `, "command": ["GET", "missing"], "expected": "(nil)"}`, gogios.StatusSuccess},
+		{"array", `{` + server(open) + `, "command": ["LRANGE", "list", "0", "-1"]}`, gogios.StatusSuccess},
+		{"error", `{` + server(open) + `, "command": ["FLUSHALL"]}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
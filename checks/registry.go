@@ -0,0 +1,132 @@
+package checks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bkasin/gogios"
+)
+
+type Creator func() gogios.Checker
+
+var Checks = map[string]Creator{}
+
+func Add(name string, creator Creator) {
+	Checks[name] = creator
+}
+
+// Spec is the part of a check list entry that picks a built in check type
+type Spec struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options"`
+}
+
+// New returns the checker for a check list entry, or nil if the entry has no
+// type and runs its command in sh. Options that the type does not know about
+// are rejected so typos do not go unnoticed
+func New(spec Spec) (gogios.Checker, error) {
+	if spec.Type == "" {
+		return nil, nil
+	}
+
+	creator, ok := Checks[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown check type %q, must be one of %v", spec.Type, Names())
+	}
+	checker := creator()
+
+	if len(spec.Options) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(spec.Options))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(checker); err != nil {
+			return nil, fmt.Errorf("%s check options: %s", spec.Type, err)
+		}
+	}
+
+	if err := checker.Init(); err != nil {
+		return nil, fmt.Errorf("%s check: %s", spec.Type, err)
+	}
+
+	return checker, nil
+}
+
+// Names returns the registered check types in order
+func Names() []string {
+	var names []string
+	for name := range Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Threshold rates a measurement where higher is worse, such as a response
+// time. A threshold of 0 is not checked
+func Threshold(value, warning, critical float64) string {
+	switch {
+	case critical > 0 && value >= critical:
+		return gogios.StatusFailed
+	case warning > 0 && value >= warning:
+		return gogios.StatusWarning
+	default:
+		return gogios.StatusSuccess
+	}
+}
+
+// Worst returns the most serious of the statuses
+func Worst(statuses ...string) string {
+	worst := gogios.StatusSuccess
+	for _, status := range statuses {
+		if gogios.Severity(status) > gogios.Severity(worst) {
+			worst = status
+		}
+	}
+
+	return worst
+}
+
+// Result builds a CheckResult, setting the exit code from the status the way
+// Nagios plugins do
+func Result(status, output string, perfdata ...gogios.Perfdata) gogios.CheckResult {
+	return gogios.CheckResult{
+		Status:   status,
+		Output:   output,
+		ExitCode: gogios.StatusCode(status),
+		Perfdata: perfdata,
+	}
+}
+
+// Failed is a shortcut for a result that failed with an error
+func Failed(err error, perfdata ...gogios.Perfdata) gogios.CheckResult {
+	return Result(gogios.StatusFailed, err.Error(), perfdata...)
+}
+
+// Compare rates a value read by a check, such as the result of a query. The
+// value must equal expected if it is set, and is rated against the thresholds
+// like Threshold if either is set. It returns the status and, when it is not
+// a success, the reason why
+func Compare(value, expected string, warning, critical float64) (string, string) {
+	value = strings.TrimSpace(value)
+	if expected != "" && value != expected {
+		return gogios.StatusFailed, fmt.Sprintf("got %q, expected %q", value, expected)
+	}
+	if warning == 0 && critical == 0 {
+		return gogios.StatusSuccess, ""
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return gogios.StatusFailed, fmt.Sprintf("%q is not a number", value)
+	}
+	status := Threshold(number, warning, critical)
+	if status != gogios.StatusSuccess {
+		return status, fmt.Sprintf("%s is over the threshold", value)
+	}
+
+	return status, ""
+}
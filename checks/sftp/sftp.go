@@ -0,0 +1,156 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/checks/ssh"
+	"github.com/bkasin/gogios/helpers"
+	"github.com/pkg/sftp"
+)
+
+// SFTP logs in over SSH, lists a directory and optionally uploads a file and
+// downloads it again
+type SFTP struct {
+	ssh.Login
+
+	// Directory to list, the login directory by default, and names that
+	// must be in it
+	Directory string   `json:"directory"`
+	Expected  []string `json:"expected"`
+
+	// Path to upload a test file to, download again and delete. The check
+	// fails unless what comes back hashes the same as what was sent
+	RoundTrip string `json:"round_trip"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+}
+
+// Description returns a brief explanation of the check
+func (s *SFTP) Description() string {
+	return "Log in over SFTP, list a directory and optionally transfer a test file"
+}
+
+// Init checks the options
+func (s *SFTP) Init() error {
+	if s.Directory == "" {
+		s.Directory = "."
+	}
+
+	return s.Setup()
+}
+
+// Run logs in, lists the directory and does the round trip
+func (s *SFTP) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	conn, _, err := s.Dial(ctx)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return fail("Logged in as %s, but could not start SFTP: %s", s.User, err)
+	}
+	defer client.Close()
+	output = append(output, fmt.Sprintf("Logged in as %s, server %s", s.User, conn.ServerVersion()))
+
+	entries, err := client.ReadDir(s.Directory)
+	if err != nil {
+		return fail("Listing %s failed: %s", s.Directory, err)
+	}
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	output = append(output, fmt.Sprintf("%s has %d entries", s.Directory, len(names)))
+	for _, name := range s.Expected {
+		if !names[name] {
+			return fail("%s is not in %s", name, s.Directory)
+		}
+	}
+
+	if s.RoundTrip != "" {
+		size, err := roundTrip(client, s.RoundTrip)
+		if err != nil {
+			return fail("Round trip through %s failed: %s", s.RoundTrip, err)
+		}
+		output = append(output, fmt.Sprintf("Uploaded and downloaded %d bytes through %s", size, s.RoundTrip))
+	}
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: s.WarningTime.Duration.Seconds(), Critical: s.CriticalTime.Duration.Seconds()},
+		{Label: "entries", Value: float64(len(names))},
+	}
+	status := checks.Threshold(elapsed.Seconds(), s.WarningTime.Duration.Seconds(), s.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// roundTrip uploads random bytes to path, downloads them again and deletes the
+// file. It returns how many bytes went each way
+func roundTrip(client *sftp.Client, path string) (int, error) {
+	sent := make([]byte, 4096)
+	if _, err := rand.Read(sent); err != nil {
+		return 0, err
+	}
+
+	file, err := client.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return 0, fmt.Errorf("upload: %s", err)
+	}
+	_, err = file.Write(sent)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("upload: %s", err)
+	}
+
+	file, err = client.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("download: %s", err)
+	}
+	received, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return 0, fmt.Errorf("download: %s", err)
+	}
+
+	if err := client.Remove(path); err != nil {
+		return 0, fmt.Errorf("delete: %s", err)
+	}
+
+	sentHash, receivedHash := sha256.Sum256(sent), sha256.Sum256(received)
+	if !bytes.Equal(sentHash[:], receivedHash[:]) {
+		return 0, fmt.Errorf("sent %d bytes with SHA-256 %x, but got back %d bytes with %x", len(sent), sentHash, len(received), receivedHash)
+	}
+
+	return len(sent), nil
+}
+
+func init() {
+	checks.Add("sftp", func() gogios.Checker {
+		return &SFTP{}
+	})
+}
@@ -0,0 +1,138 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// server starts an SSH server that accepts the password hunter2 and serves
+// the returned directory over SFTP
+func server(t *testing.T) (addr string, hostKey ssh.PublicKey, root string) {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostPriv)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "gogios" && string(password) == "hunter2" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	root = t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "readme.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Could not write a file, got error: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, config, root)
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey(), root
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig, root string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "subsystem" || string(req.Payload[4:]) != "sftp" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					return
+				}
+				server.Serve()
+				return
+			}
+		}()
+	}
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "sftp", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	addr, hostKey, root := server(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	base := fmt.Sprintf(`"host": %q, "port": %s, "user": "gogios", "host_key": %q`, host, port, ssh.FingerprintSHA256(hostKey))
+	login := base + `, "password": "hunter2"`
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"login", `{` + login + `}`, gogios.StatusSuccess},
+		{"wrong password", `{` + base + `, "password": "hunter3"}`, gogios.StatusFailed},
+		{"expected", `{` + login + `, "directory": "` + root + `", "expected": ["readme.txt"]}`, gogios.StatusSuccess},
+		{"missing entry", `{` + login + `, "expected": ["backup.tar"]}`, gogios.StatusFailed},
+		{"missing directory", `{` + login + `, "directory": "nope"}`, gogios.StatusFailed},
+		{"round trip", `{` + login + `, "round_trip": "gogios.tmp"}`, gogios.StatusSuccess},
+		{"unwritable", `{` + login + `, "round_trip": "nope/gogios.tmp"}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "gogios.tmp")); !os.IsNotExist(err) {
+		t.Error("The round trip file was not deleted")
+	}
+}
@@ -0,0 +1,270 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// SMTP talks to a mail server up to EHLO, and optionally upgrades to TLS,
+// logs in and sends a test message
+type SMTP struct {
+	Host string `json:"host"`
+	Port int    `json:"port"` // 25 by default, or 465 with tls
+
+	// Connect over TLS from the start, or upgrade with STARTTLS, which then
+	// has to be offered
+	TLS      bool `json:"tls"`
+	StartTLS bool `json:"starttls"`
+	checks.TLSOptions
+
+	// Regular expression that the greeting must match, such as "ESMTP Postfix"
+	Expect string `json:"expect"`
+	// Name sent with EHLO
+	Helo string `json:"helo"`
+
+	// Log in with AUTH PLAIN. The password is a secret reference, such as
+	// "env:SMTP_PASSWORD"
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Send a test message from and to these addresses when to is set
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+
+	// Times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	password  string
+	expect    *regexp.Regexp
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (s *SMTP) Description() string {
+	return "Talk to an SMTP server and optionally log in and send a test message"
+}
+
+// Init checks the options
+func (s *SMTP) Init() error {
+	if s.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if s.TLS && s.StartTLS {
+		return fmt.Errorf("tls and starttls cannot both be set")
+	}
+	if s.Port == 0 {
+		s.Port = 25
+		if s.TLS {
+			s.Port = 465
+		}
+	}
+	s.address = net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	if s.Helo == "" {
+		s.Helo = "gogios"
+	}
+	if s.To != "" && s.From == "" {
+		return fmt.Errorf("from is required to send a test message")
+	}
+	if s.Subject == "" {
+		s.Subject = "gogios test message"
+	}
+
+	var err error
+	if s.Expect != "" {
+		s.expect, err = regexp.Compile(s.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %s", err)
+		}
+	}
+
+	if s.Username != "" {
+		s.password, err = helpers.ResolveSecret(s.Password)
+		if err != nil {
+			return fmt.Errorf("password: %s", err)
+		}
+	}
+
+	if s.TLS || s.StartTLS {
+		s.tlsConfig, err = s.TLSConfig(s.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run has the conversation with the server
+func (s *SMTP) Run(ctx context.Context) gogios.CheckResult {
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	if s.TLS {
+		dialer := tls.Dialer{Config: s.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", s.address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", s.address)
+	}
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var output []string
+	fail := func(format string, args ...interface{}) gogios.CheckResult {
+		output = append(output, fmt.Sprintf(format, args...))
+		return checks.Result(gogios.StatusFailed, strings.Join(output, "\n"))
+	}
+
+	text := textproto.NewConn(conn)
+	_, greeting, err := text.ReadResponse(220)
+	if err != nil {
+		return fail("Bad greeting: %s", err)
+	}
+	output = append(output, "220 "+greeting)
+	if s.expect != nil && !s.expect.MatchString(greeting) {
+		return fail("Greeting does not match %q", s.Expect)
+	}
+
+	extensions, err := s.hello(text)
+	if err != nil {
+		return fail("EHLO failed: %s", err)
+	}
+
+	if s.StartTLS {
+		if _, ok := extensions["STARTTLS"]; !ok {
+			return fail("STARTTLS is not offered")
+		}
+		if _, err := command(text, 220, "STARTTLS"); err != nil {
+			return fail("STARTTLS failed: %s", err)
+		}
+
+		tlsConn := tls.Client(conn, s.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail("TLS handshake failed: %s", err)
+		}
+		text = textproto.NewConn(tlsConn)
+		output = append(output, "Started TLS")
+
+		extensions, err = s.hello(text)
+		if err != nil {
+			return fail("EHLO after STARTTLS failed: %s", err)
+		}
+	}
+
+	if s.Username != "" {
+		if !strings.Contains(" "+extensions["AUTH"]+" ", " PLAIN ") {
+			return fail("AUTH PLAIN is not offered")
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + s.Username + "\x00" + s.password))
+		if _, err := command(text, 235, "AUTH PLAIN %s", credentials); err != nil {
+			return fail("Login as %s failed: %s", s.Username, err)
+		}
+		output = append(output, "Logged in as "+s.Username)
+	}
+
+	if s.To != "" {
+		queued, err := s.send(text)
+		if err != nil {
+			return fail("Sending a test message to %s failed: %s", s.To, err)
+		}
+		output = append(output, "Sent a test message to "+s.To+": "+queued)
+	}
+
+	command(text, 221, "QUIT")
+	elapsed := time.Since(start)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: s.WarningTime.Duration.Seconds(), Critical: s.CriticalTime.Duration.Seconds()},
+	}
+	status := checks.Threshold(elapsed.Seconds(), s.WarningTime.Duration.Seconds(), s.CriticalTime.Duration.Seconds())
+
+	return checks.Result(status, strings.Join(output, "\n"), perfdata...)
+}
+
+// hello sends EHLO and returns the extensions the server offers with their
+// parameters
+func (s *SMTP) hello(text *textproto.Conn) (map[string]string, error) {
+	reply, err := command(text, 250, "EHLO %s", s.Helo)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := map[string]string{}
+	lines := strings.Split(reply, "\n")
+	for _, line := range lines[1:] {
+		name, params, _ := strings.Cut(line, " ")
+		extensions[strings.ToUpper(name)] = params
+	}
+
+	return extensions, nil
+}
+
+// send sends the test message and returns what the server said about it
+func (s *SMTP) send(text *textproto.Conn) (string, error) {
+	if _, err := command(text, 250, "MAIL FROM:<%s>", s.From); err != nil {
+		return "", err
+	}
+	if _, err := command(text, 25, "RCPT TO:<%s>", s.To); err != nil {
+		return "", err
+	}
+	if _, err := command(text, 354, "DATA"); err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("From: <%s>\nTo: <%s>\nSubject: %s\nDate: %s\n\nSent by gogios to check that mail is accepted.\n",
+		s.From, s.To, s.Subject, time.Now().Format(time.RFC1123Z))
+	// The writer turns line endings into CRLF and escapes leading dots
+	w := text.DotWriter()
+	if _, err := w.Write([]byte(message)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	_, queued, err := text.ReadResponse(250)
+	return queued, err
+}
+
+// command sends a line and reads the reply, which must start with expectCode
+func command(text *textproto.Conn, expectCode int, format string, args ...interface{}) (string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	_, message, err := text.ReadResponse(expectCode)
+	return message, err
+}
+
+func init() {
+	checks.Add("smtp", func() gogios.Checker {
+		return &SMTP{}
+	})
+}
@@ -0,0 +1,176 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// serve starts a stand-in mail server that offers STARTTLS, accepts the
+// password hunter2 and only delivers to sink@example.com. It returns its
+// address, the path of a CA that trusts it and the messages it received
+func serve(t *testing.T) (string, string, chan string) {
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(https.Close)
+	config := &tls.Config{Certificates: https.TLS.Certificates}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go converse(conn, config, messages)
+		}
+	}()
+
+	return listener.Addr().String(), caCert, messages
+}
+
+func converse(conn net.Conn, config *tls.Config, messages chan string) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220-mail.example.com ESMTP Stand-in")
+	text.PrintfLine("220 ready")
+
+	secure := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-mail.example.com")
+			if !secure {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH LOGIN PLAIN")
+		case "STARTTLS":
+			text.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, config)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+			secure = true
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if string(credentials) == "\x00gogios\x00hunter2" {
+				text.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				text.PrintfLine("535 5.7.8 Authentication failed")
+			}
+		case "MAIL":
+			text.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			if arg == "TO:<sink@example.com>" {
+				text.PrintfLine("250 2.1.5 Ok")
+			} else {
+				text.PrintfLine("550 5.1.1 No such user")
+			}
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			lines, _ := text.ReadDotLines()
+			messages <- strings.Join(lines, "\n")
+			text.PrintfLine("250 2.0.0 Ok: queued as 1")
+		case "QUIT":
+			text.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			text.PrintfLine("502 5.5.2 Error: command not recognized")
+		}
+	}
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "smtp", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	addr, caCert, _ := serve(t)
+	host, port, _ := net.SplitHostPort(addr)
+	base := fmt.Sprintf(`"host": %q, "port": %s`, host, port)
+	secure := base + fmt.Sprintf(`, "starttls": true, "ca_cert": %q`, caCert)
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"ehlo", `{` + base + `}`, gogios.StatusSuccess},
+		{"greeting", `{` + base + `, "expect": "ESMTP Stand-in"}`, gogios.StatusSuccess},
+		{"wrong greeting", `{` + base + `, "expect": "Postfix"}`, gogios.StatusFailed},
+		{"starttls", `{` + secure + `}`, gogios.StatusSuccess},
+		{"untrusted", `{` + base + `, "starttls": true}`, gogios.StatusFailed},
+		{"login", `{` + secure + `, "username": "gogios", "password": "hunter2"}`, gogios.StatusSuccess},
+		{"wrong password", `{` + secure + `, "username": "gogios", "password": "hunter3"}`, gogios.StatusFailed},
+		{"rejected", `{` + base + `, "from": "gogios@example.com", "to": "nobody@example.com"}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	addr, caCert, messages := serve(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	result := run(t, fmt.Sprintf(`{"host": %q, "port": %s, "starttls": true, "ca_cert": %q, "from": "gogios@example.com", "to": "sink@example.com", "subject": "Test"}`, host, port, caCert))
+	if result.Status != gogios.StatusSuccess || !strings.Contains(result.Output, "queued as 1") {
+		t.Fatalf("Expected the message to be sent, got %s: %s", result.Status, result.Output)
+	}
+
+	select {
+	case message := <-messages:
+		if !strings.Contains(message, "To: <sink@example.com>") || !strings.Contains(message, "Subject: Test") {
+			t.Errorf("Message was wrong, got: %s", message)
+		}
+	default:
+		t.Error("The server did not get a message")
+	}
+}
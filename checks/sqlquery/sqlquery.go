@@ -0,0 +1,153 @@
+// Package sqlquery holds the login and query logic shared by the mysql and
+// postgres check types. Each embeds a Query and hands its driver to Query.Run
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// maxRows is how many rows are read from the result of the query
+const maxRows = 1000
+
+// shownRows is how many rows are put in the output
+const shownRows = 10
+
+// Query is the part of the options about what to run once logged in
+type Query struct {
+	// Query to run, such as "SELECT 1". Without one the check only logs in
+	Query string `json:"query"`
+	// The first column of the first row must equal this
+	Expected string `json:"expected"`
+	// The query must return this many rows
+	Rows *int `json:"rows"`
+	// The first column of the first row above these, such as a replication
+	// lag in seconds, makes the check a warning or a failure
+	WarningValue  float64 `json:"warning_value"`
+	CriticalValue float64 `json:"critical_value"`
+
+	// Query times above these make the check a warning or a failure. Without
+	// a query they apply to logging in
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+}
+
+// Run logs in through the connector and runs the query. name describes the
+// server in the output
+func (q Query) Run(ctx context.Context, connector driver.Connector, name string) gogios.CheckResult {
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	start := time.Now()
+	conn, err := db.Conn(ctx)
+	connectTime := time.Since(start)
+	if err != nil {
+		return checks.Failed(fmt.Errorf("login to %s failed: %s", name, err))
+	}
+	defer conn.Close()
+
+	warning, critical := q.WarningTime.Duration.Seconds(), q.CriticalTime.Duration.Seconds()
+	perfdata := []gogios.Perfdata{{Label: "connect_time", Value: connectTime.Seconds(), Unit: "s"}}
+	output := fmt.Sprintf("Logged in to %s in %s", name, connectTime.Round(time.Millisecond))
+
+	if q.Query == "" {
+		perfdata[0].Warning, perfdata[0].Critical = warning, critical
+		return checks.Result(checks.Threshold(connectTime.Seconds(), warning, critical), output, perfdata...)
+	}
+
+	start = time.Now()
+	rows, err := q.read(ctx, conn)
+	queryTime := time.Since(start)
+	perfdata = append(perfdata,
+		gogios.Perfdata{Label: "query_time", Value: queryTime.Seconds(), Unit: "s", Warning: warning, Critical: critical},
+		gogios.Perfdata{Label: "rows", Value: float64(len(rows))},
+	)
+	if err != nil {
+		return checks.Failed(fmt.Errorf("%s, but the query failed: %s", output, err), perfdata...)
+	}
+
+	output += fmt.Sprintf(", the query returned %d rows in %s", len(rows), queryTime.Round(time.Millisecond))
+	for i, row := range rows {
+		if i == shownRows {
+			output += "\n..."
+			break
+		}
+		output += "\n" + strings.Join(row, "\t")
+	}
+
+	status := checks.Threshold(queryTime.Seconds(), warning, critical)
+	var problems []string
+
+	if q.Rows != nil && len(rows) != *q.Rows {
+		status = gogios.StatusFailed
+		problems = append(problems, fmt.Sprintf("expected %d rows", *q.Rows))
+	}
+
+	if q.Expected != "" || q.WarningValue != 0 || q.CriticalValue != 0 {
+		if len(rows) == 0 {
+			status = gogios.StatusFailed
+			problems = append(problems, "no rows to check the value of")
+		} else {
+			valueStatus, problem := checks.Compare(rows[0][0], q.Expected, q.WarningValue, q.CriticalValue)
+			status = checks.Worst(status, valueStatus)
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		output += "\n" + strings.Join(problems, "\n")
+	}
+
+	return checks.Result(status, output, perfdata...)
+}
+
+// read runs the query and returns up to maxRows rows, with NULL as "NULL"
+func (q Query) read(ctx context.Context, conn *sql.Conn) ([][]string, error) {
+	rows, err := conn.QueryContext(ctx, q.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	var result [][]string
+	for rows.Next() && len(result) < maxRows {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(columns))
+		for i, value := range values {
+			row[i] = "NULL"
+			if value.Valid {
+				row[i] = value.String
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,56 @@
+package sqlquery
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks/sqlquery/sqlquerytest"
+)
+
+func TestRun(t *testing.T) {
+	lag := &sqlquerytest.Connector{Columns: []string{"lag", "host"}, Rows: [][]driver.Value{{int64(12), "replica"}, {nil, "primary"}}}
+	two := 2
+	one := 1
+
+	tests := []struct {
+		name      string
+		query     Query
+		connector *sqlquerytest.Connector
+		status    string
+	}{
+		{"login", Query{}, lag, gogios.StatusSuccess},
+		{"login failed", Query{}, &sqlquerytest.Connector{Err: errors.New("access denied")}, gogios.StatusFailed},
+		{"query", Query{Query: "SELECT"}, lag, gogios.StatusSuccess},
+		{"broken query", Query{Query: "broken"}, lag, gogios.StatusFailed},
+		{"expected", Query{Query: "SELECT", Expected: "12"}, lag, gogios.StatusSuccess},
+		{"unexpected", Query{Query: "SELECT", Expected: "0"}, lag, gogios.StatusFailed},
+		{"rows", Query{Query: "SELECT", Rows: &two}, lag, gogios.StatusSuccess},
+		{"wrong rows", Query{Query: "SELECT", Rows: &one}, lag, gogios.StatusFailed},
+		{"lag warning", Query{Query: "SELECT", WarningValue: 10, CriticalValue: 60}, lag, gogios.StatusWarning},
+		{"lag critical", Query{Query: "SELECT", WarningValue: 5, CriticalValue: 10}, lag, gogios.StatusFailed},
+		{"no rows", Query{Query: "SELECT", Expected: "1"}, &sqlquerytest.Connector{Columns: []string{"x"}}, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := test.query.Run(context.Background(), test.connector, "fake")
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	lag := &sqlquerytest.Connector{Columns: []string{"lag", "host"}, Rows: [][]driver.Value{{int64(12), "replica"}, {nil, "primary"}}}
+
+	result := Query{Query: "SELECT"}.Run(context.Background(), lag, "fake")
+	if !strings.Contains(result.Output, "returned 2 rows") || !strings.HasSuffix(result.Output, "\n12\treplica\nNULL\tprimary") {
+		t.Errorf("Output was wrong, got: %s", result.Output)
+	}
+	if len(result.Perfdata) != 3 || result.Perfdata[2].Value != 2 {
+		t.Errorf("Expected connect_time, query_time and rows perfdata, got: %v", result.Perfdata)
+	}
+}
@@ -0,0 +1,63 @@
+// Package sqlquerytest provides a fake database driver for testing the check
+// types built on sqlquery without a server
+package sqlquerytest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+)
+
+// Connector answers every query with the same rows. Connecting fails with Err
+// if it is set, and the query "broken" always fails
+type Connector struct {
+	Columns []string
+	Rows    [][]driver.Value
+	Err     error
+	// How long each query takes
+	Delay time.Duration
+
+	// The last query that was run
+	Query string
+}
+
+func (c *Connector) Connect(context.Context) (driver.Conn, error) { return c, c.Err }
+func (c *Connector) Driver() driver.Driver                        { return nil }
+func (c *Connector) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (c *Connector) Close() error                                 { return nil }
+func (c *Connector) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+// QueryContext returns the rows after the delay
+func (c *Connector) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.Query = query
+	if query == "broken" {
+		return nil, errors.New("syntax error")
+	}
+
+	select {
+	case <-time.After(c.Delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return &rows{Connector: c}, nil
+}
+
+type rows struct {
+	*Connector
+	next int
+}
+
+func (r *rows) Columns() []string { return r.Connector.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.Rows[r.next])
+	r.next++
+	return nil
+}
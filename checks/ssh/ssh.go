@@ -0,0 +1,235 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+	"golang.org/x/crypto/ssh"
+)
+
+// Login holds the options for logging in to an SSH server, shared by the check
+// types that work over SSH
+type Login struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	User string `json:"user"`
+
+	// Password, private key and key passphrase are secret references, such
+	// as "env:SSH_PASSWORD" or "file:/etc/gogios/id_ed25519"
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase"`
+
+	// The server's public key as in known_hosts, such as "ssh-ed25519 AAAA...",
+	// or its fingerprint, such as "SHA256:...". Required unless
+	// insecure_ignore_host_key is set
+	HostKey               string `json:"host_key"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key"`
+
+	address string
+	config  *ssh.ClientConfig
+}
+
+// SSH logs in to a server and optionally runs a command there
+type SSH struct {
+	Login
+
+	// Command to run after logging in, which must exit with 0 and print
+	// expected if it is set
+	Command  string `json:"command"`
+	Expected string `json:"expected"`
+
+	// Login times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+}
+
+// Description returns a brief explanation of the check
+func (s *SSH) Description() string {
+	return "Log in over SSH and optionally run a command"
+}
+
+// Init checks the options
+func (s *SSH) Init() error {
+	return s.Setup()
+}
+
+// Setup checks the login options, reads the secrets and parses the keys
+func (l *Login) Setup() error {
+	if l.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if l.User == "" {
+		return fmt.Errorf("user is required")
+	}
+	if l.Port == 0 {
+		l.Port = 22
+	}
+	l.address = net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+
+	l.config = &ssh.ClientConfig{User: l.User}
+
+	if l.PrivateKey != "" {
+		pem, err := helpers.ResolveSecret(l.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("private_key: %s", err)
+		}
+		passphrase, err := helpers.ResolveSecret(l.Passphrase)
+		if err != nil {
+			return fmt.Errorf("passphrase: %s", err)
+		}
+
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(pem), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(pem))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid private_key: %s", err)
+		}
+		l.config.Auth = append(l.config.Auth, ssh.PublicKeys(signer))
+	}
+
+	if l.Password != "" {
+		password, err := helpers.ResolveSecret(l.Password)
+		if err != nil {
+			return fmt.Errorf("password: %s", err)
+		}
+		l.config.Auth = append(l.config.Auth, ssh.Password(password))
+	}
+
+	if len(l.config.Auth) == 0 {
+		return fmt.Errorf("password or private_key is required")
+	}
+
+	switch {
+	case l.HostKey != "":
+		callback, algorithms, err := pin(l.HostKey)
+		if err != nil {
+			return err
+		}
+		l.config.HostKeyCallback = callback
+		l.config.HostKeyAlgorithms = algorithms
+	case l.InsecureIgnoreHostKey:
+		l.config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return fmt.Errorf("host_key is required unless insecure_ignore_host_key is set")
+	}
+
+	return nil
+}
+
+// pin returns a callback that only accepts the given host key or fingerprint,
+// and the algorithms to ask the server for so it presents that key
+func pin(hostKey string) (ssh.HostKeyCallback, []string, error) {
+	if strings.HasPrefix(hostKey, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fingerprint := ssh.FingerprintSHA256(key); fingerprint != hostKey {
+				return fmt.Errorf("host key %s does not match the pinned %s", fingerprint, hostKey)
+			}
+			return nil
+		}, nil, nil
+	}
+
+	pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid host_key: %s", err)
+	}
+
+	algorithms := []string{pinned.Type()}
+	if pinned.Type() == ssh.KeyAlgoRSA {
+		algorithms = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+
+	return ssh.FixedHostKey(pinned), algorithms, nil
+}
+
+// Dial connects and logs in, and returns the client and how long logging in
+// took. The connection is cut when ctx is done
+func (l *Login) Dial(ctx context.Context) (*ssh.Client, time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", l.address)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	start := time.Now()
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, l.address, l.config)
+	elapsed := time.Since(start)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, elapsed, fmt.Errorf("login to %s failed: %s", l.address, err)
+	}
+
+	return ssh.NewClient(clientConn, channels, requests), elapsed, nil
+}
+
+// Run logs in and runs the command
+func (s *SSH) Run(ctx context.Context) gogios.CheckResult {
+	client, elapsed, err := s.Dial(ctx)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer client.Close()
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: s.WarningTime.Duration.Seconds(), Critical: s.CriticalTime.Duration.Seconds()},
+	}
+	output := fmt.Sprintf("Logged in to %s as %s in %s, server %s", s.address, s.User, elapsed.Round(time.Millisecond), client.ServerVersion())
+	status := checks.Threshold(elapsed.Seconds(), s.WarningTime.Duration.Seconds(), s.CriticalTime.Duration.Seconds())
+
+	if s.Command == "" {
+		return checks.Result(status, output, perfdata...)
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return checks.Failed(fmt.Errorf("%s, but could not open a session: %s", output, err), perfdata...)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(s.Command)
+
+	result := checks.Result(status, output+"\n"+stdout.String(), perfdata...)
+	result.Stderr = stderr.String()
+
+	var exitErr *ssh.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.Status = gogios.StatusFailed
+		result.Output += fmt.Sprintf("\n%s exited with %d", s.Command, exitErr.ExitStatus())
+	case err != nil:
+		result.Status = gogios.StatusFailed
+		result.Output += fmt.Sprintf("\n%s failed: %s", s.Command, err)
+	case !strings.Contains(stdout.String(), s.Expected):
+		result.Status = gogios.StatusFailed
+		result.Output += fmt.Sprintf("\nExpected %q in the output", s.Expected)
+	}
+	result.ExitCode = gogios.StatusCode(result.Status)
+
+	return result
+}
+
+func init() {
+	checks.Add("ssh", func() gogios.Checker {
+		return &SSH{}
+	})
+}
@@ -0,0 +1,174 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"golang.org/x/crypto/ssh"
+)
+
+// server starts an SSH server that accepts the password "hunter2" or the
+// returned client key, and runs "echo" and "false" commands
+func server(t *testing.T) (addr string, hostKey ssh.PublicKey, clientKey string) {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostPriv)
+
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	authorized, _ := ssh.NewPublicKey(clientPub)
+	der, err := x509.MarshalPKCS8PrivateKey(clientPriv)
+	if err != nil {
+		t.Fatalf("Could not marshal the client key, got error: %s", err)
+	}
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "gogios" && string(password) == "hunter2" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, config)
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey(), string(pem.EncodeToMemory(block))
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				command := string(req.Payload[4:])
+				status := uint32(0)
+				if strings.HasPrefix(command, "echo ") {
+					fmt.Fprintln(channel, strings.TrimPrefix(command, "echo "))
+				} else {
+					fmt.Fprintln(channel.Stderr(), "no")
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "ssh", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	addr, hostKey, clientKey := server(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, []byte(clientKey), 0600); err != nil {
+		t.Fatalf("Could not write the key, got error: %s", err)
+	}
+	t.Setenv("GOGIOS_SSH_PASSWORD", "hunter2")
+
+	base := fmt.Sprintf(`"host": %q, "port": %s, "user": "gogios", "host_key": %q`, host, port, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey))))
+	password := base + `, "password": "env:GOGIOS_SSH_PASSWORD"`
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"password", `{` + password + `}`, gogios.StatusSuccess},
+		{"wrong password", `{` + base + `, "password": "hunter3"}`, gogios.StatusFailed},
+		{"key", `{` + base + `, "private_key": "file:` + keyFile + `"}`, gogios.StatusSuccess},
+		{"fingerprint", fmt.Sprintf(`{"host": %q, "port": %s, "user": "gogios", "password": "hunter2", "host_key": %q}`, host, port, ssh.FingerprintSHA256(hostKey)), gogios.StatusSuccess},
+		{"wrong fingerprint", fmt.Sprintf(`{"host": %q, "port": %s, "user": "gogios", "password": "hunter2", "host_key": "SHA256:nope"}`, host, port), gogios.StatusFailed},
+		{"command", `{` + password + `, "command": "echo working", "expected": "working"}`, gogios.StatusSuccess},
+		{"wrong output", `{` + password + `, "command": "echo broken", "expected": "working"}`, gogios.StatusFailed},
+		{"failed command", `{` + password + `, "command": "false"}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{"user": "gogios", "password": "x", "insecure_ignore_host_key": true}`,
+		`{"host": "example.com", "password": "x", "insecure_ignore_host_key": true}`,
+		`{"host": "example.com", "user": "gogios", "insecure_ignore_host_key": true}`,
+		`{"host": "example.com", "user": "gogios", "password": "x"}`,
+		`{"host": "example.com", "user": "gogios", "password": "env:GOGIOS_UNSET_SECRET", "insecure_ignore_host_key": true}`,
+		`{"host": "example.com", "user": "gogios", "private_key": "not a key", "insecure_ignore_host_key": true}`,
+		`{"host": "example.com", "user": "gogios", "password": "x", "host_key": "not a key"}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "ssh", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+	"github.com/bkasin/gogios/helpers"
+)
+
+// maxBanner is how much is read while waiting for the expected banner
+const maxBanner = 4096
+
+// TCP connects to a port, optionally sends a string and checks the reply
+type TCP struct {
+	Host           string           `json:"host"`
+	Port           int              `json:"port"`
+	ConnectTimeout helpers.Duration `json:"connect_timeout"`
+
+	// Sent once connected, such as "HEAD / HTTP/1.0\r\n\r\n"
+	Send string `json:"send"`
+	// Regular expression that the reply must match, such as "^SSH-2.0"
+	Expect string `json:"expect"`
+
+	// Connect over TLS and verify the certificate
+	TLS bool `json:"tls"`
+	checks.TLSOptions
+
+	// Connect times above these make the check a warning or a failure
+	WarningTime  helpers.Duration `json:"warning_time"`
+	CriticalTime helpers.Duration `json:"critical_time"`
+
+	address   string
+	expect    *regexp.Regexp
+	tlsConfig *tls.Config
+}
+
+// Description returns a brief explanation of the check
+func (t *TCP) Description() string {
+	return "Connect to a TCP port and optionally check the banner it sends"
+}
+
+// Init checks the options
+func (t *TCP) Init() error {
+	if t.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if t.Port <= 0 || t.Port > 65535 {
+		return fmt.Errorf("port %d is not between 1 and 65535", t.Port)
+	}
+	t.address = net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+
+	if t.ConnectTimeout.Duration <= 0 {
+		t.ConnectTimeout.Duration = 10 * time.Second
+	}
+
+	var err error
+	if t.Expect != "" {
+		t.expect, err = regexp.Compile(t.Expect)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %s", err)
+		}
+	}
+
+	if t.TLS {
+		t.tlsConfig, err = t.TLSConfig(t.Host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run connects, sends and reads the banner
+func (t *TCP) Run(ctx context.Context) gogios.CheckResult {
+	dialer := net.Dialer{Timeout: t.ConnectTimeout.Duration}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	elapsed := time.Since(start)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	perfdata := []gogios.Perfdata{
+		{Label: "time", Value: elapsed.Seconds(), Unit: "s", Warning: t.WarningTime.Duration.Seconds(), Critical: t.CriticalTime.Duration.Seconds()},
+	}
+	output := fmt.Sprintf("Connected to %s in %s", t.address, elapsed.Round(time.Millisecond))
+
+	// Reads and writes give up when the check runs out of time
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if t.TLS {
+		tlsConn := tls.Client(conn, t.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return checks.Failed(fmt.Errorf("%s, but the TLS handshake failed: %s", output, err), perfdata...)
+		}
+		conn = tlsConn
+	}
+
+	if t.Send != "" {
+		if _, err := conn.Write([]byte(t.Send)); err != nil {
+			return checks.Failed(fmt.Errorf("%s, but sending failed: %s", output, err), perfdata...)
+		}
+	}
+
+	if t.expect != nil {
+		reply, err := readUntil(conn, t.expect)
+		if err != nil {
+			return checks.Failed(fmt.Errorf("%s, but the reply did not match %q: %s\n%s", output, t.Expect, err, reply), perfdata...)
+		}
+		output += "\n" + reply
+	}
+
+	return checks.Result(checks.Threshold(elapsed.Seconds(), t.WarningTime.Duration.Seconds(), t.CriticalTime.Duration.Seconds()), output, perfdata...)
+}
+
+// readUntil reads from the connection until what it has read matches re. It
+// returns what was read even when there is no match
+func readUntil(conn net.Conn, re *regexp.Regexp) (string, error) {
+	buf := make([]byte, 0, maxBanner)
+	chunk := make([]byte, 512)
+
+	for len(buf) < maxBanner {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if re.Match(buf) {
+			return strings.TrimSpace(string(buf)), nil
+		}
+		if err != nil {
+			return strings.TrimSpace(string(buf)), err
+		}
+	}
+
+	return strings.TrimSpace(string(buf)), fmt.Errorf("no match in the first %d bytes", maxBanner)
+}
+
+func init() {
+	checks.Add("tcp", func() gogios.Checker {
+		return &TCP{}
+	})
+}
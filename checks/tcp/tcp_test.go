@@ -0,0 +1,115 @@
+package tcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// listen starts a server that sends a banner and then echoes what it gets
+func listen(t *testing.T) (string, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("220 ready\r\n"))
+				buf := make([]byte, 512)
+				n, _ := conn.Read(buf)
+				conn.Write(buf[:n])
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "tcp", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	host, port := listen(t)
+	base := fmt.Sprintf(`"host": %q, "port": %d`, host, port)
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"connect", `{` + base + `}`, gogios.StatusSuccess},
+		{"banner", `{` + base + `, "expect": "^220 "}`, gogios.StatusSuccess},
+		{"wrong banner", `{` + base + `, "expect": "^500 "}`, gogios.StatusFailed},
+		{"send", `{` + base + `, "send": "PING\r\n", "expect": "PING"}`, gogios.StatusSuccess},
+		{"slow", `{` + base + `, "warning_time": "1ns"}`, gogios.StatusWarning},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+func TestClosedPort(t *testing.T) {
+	host, port := listen(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	result := run(t, fmt.Sprintf(`{"host": %q, "port": %d}`, host, port))
+	if result.Status != gogios.StatusFailed {
+		t.Errorf("A closed port should fail, got %s: %s", result.Status, result.Output)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	options := fmt.Sprintf(`{"host": %q, "port": %d, "tls": true, "send": "HEAD / HTTP/1.0\r\n\r\n", "expect": "^HTTP/1.0 200"`, addr.IP.String(), addr.Port)
+
+	result := run(t, options+`}`)
+	if result.Status != gogios.StatusFailed || !strings.Contains(result.Output, "TLS handshake failed") {
+		t.Errorf("An untrusted certificate should fail, got %s: %s", result.Status, result.Output)
+	}
+
+	result = run(t, options+`, "insecure_skip_verify": true}`)
+	if result.Status != gogios.StatusSuccess {
+		t.Errorf("Expected success, got %s: %s", result.Status, result.Output)
+	}
+}
@@ -0,0 +1,38 @@
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions are the certificate settings shared by the check types that can
+// connect over TLS
+type TLSOptions struct {
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	CACert             string `json:"ca_cert"`     // PEM file of extra CAs to trust
+	ServerName         string `json:"server_name"` // Name to verify the certificate against
+}
+
+// TLSConfig builds the client config for the options. serverName is used when
+// no server_name was given
+func (o TLSOptions) TLSConfig(serverName string) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify, ServerName: serverName}
+	if o.ServerName != "" {
+		config.ServerName = o.ServerName
+	}
+
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca_cert: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert %s has no certificates", o.CACert)
+		}
+	}
+
+	return config, nil
+}
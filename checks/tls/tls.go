@@ -0,0 +1,272 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// defaultPorts are used when no port is given, by STARTTLS protocol
+var defaultPorts = map[string]int{
+	"":         443,
+	"smtp":     25,
+	"imap":     143,
+	"ftp":      21,
+	"postgres": 5432,
+}
+
+// TLS connects to a server and checks its certificate chain, hostname and
+// expiry date
+type TLS struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	// Protocol to upgrade the connection with first: smtp, imap, ftp or
+	// postgres. Empty for servers that start with TLS
+	StartTLS string `json:"starttls"`
+
+	// insecure_skip_verify only reports the expiry date, without checking
+	// the chain or hostname
+	checks.TLSOptions
+
+	// Days left before the certificate expires at which the check becomes a
+	// warning or a failure, 30 and 7 by default. 0 turns a threshold off, and
+	// an expired certificate always fails
+	WarningDays  *int `json:"warning_days"`
+	CriticalDays *int `json:"critical_days"`
+
+	address      string
+	tlsConfig    *tls.Config
+	verify       bool
+	warningDays  int
+	criticalDays int
+}
+
+// Description returns a brief explanation of the check
+func (t *TLS) Description() string {
+	return "Check that a certificate is valid for the host and is not about to expire"
+}
+
+// Init checks the options
+func (t *TLS) Init() error {
+	if t.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+
+	t.StartTLS = strings.ToLower(t.StartTLS)
+	port, ok := defaultPorts[t.StartTLS]
+	if !ok {
+		return fmt.Errorf("starttls must be smtp, imap, ftp or postgres, not %q", t.StartTLS)
+	}
+	if t.Port == 0 {
+		t.Port = port
+	}
+	if t.Port < 0 || t.Port > 65535 {
+		return fmt.Errorf("port %d is not between 1 and 65535", t.Port)
+	}
+	t.address = net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+
+	t.warningDays, t.criticalDays = 30, 7
+	if t.WarningDays != nil {
+		t.warningDays = *t.WarningDays
+	}
+	if t.CriticalDays != nil {
+		t.criticalDays = *t.CriticalDays
+	}
+	if t.warningDays < 0 || t.criticalDays < 0 {
+		return fmt.Errorf("warning_days and critical_days cannot be negative")
+	}
+
+	var err error
+	t.tlsConfig, err = t.TLSConfig(t.Host)
+	if err != nil {
+		return err
+	}
+
+	// The chain is verified after the handshake so that the expiry date can
+	// be reported even when the chain is not trusted
+	t.verify = !t.tlsConfig.InsecureSkipVerify
+	t.tlsConfig.InsecureSkipVerify = true
+
+	return nil
+}
+
+// Run connects, upgrades the connection if needed and checks the certificate
+func (t *TLS) Run(ctx context.Context) gogios.CheckResult {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return checks.Failed(err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err := startTLS(conn, t.StartTLS); err != nil {
+		return checks.Failed(fmt.Errorf("%s STARTTLS failed: %s", t.StartTLS, err))
+	}
+
+	tlsConn := tls.Client(conn, t.tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return checks.Failed(fmt.Errorf("TLS handshake failed: %s", err))
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return checks.Failed(fmt.Errorf("no certificate was sent"))
+	}
+
+	// Servers often send certificates that are not part of the chain that is
+	// trusted, such as old cross-signs, so only the verified chain counts
+	chain := certs[:1]
+	var verifyErr error
+	if t.verify {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		var chains [][]*x509.Certificate
+		chains, verifyErr = certs[0].Verify(x509.VerifyOptions{
+			DNSName:       t.tlsConfig.ServerName,
+			Roots:         t.tlsConfig.RootCAs,
+			Intermediates: intermediates,
+		})
+		if verifyErr == nil {
+			chain = chains[0]
+		}
+	}
+
+	// The chain is only as good as the first certificate in it to expire
+	first := chain[0]
+	for _, cert := range chain[1:] {
+		if cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	expires := first.NotAfter
+	days := math.Floor(time.Until(expires).Hours() / 24)
+
+	perfdata := []gogios.Perfdata{
+		{Label: "days", Value: days, Warning: float64(t.warningDays), Critical: float64(t.criticalDays)},
+	}
+	output := fmt.Sprintf("Certificate for %s expires on %s, in %.0f days\nSubject: %s\nIssuer: %s", t.tlsConfig.ServerName, expires.Format("2006-01-02"), days, certs[0].Subject, certs[0].Issuer)
+	if first != certs[0] {
+		output += fmt.Sprintf("\n%s in the chain expires first", first.Subject)
+	}
+
+	status := gogios.StatusSuccess
+	switch {
+	case days < 0:
+		status = gogios.StatusFailed
+		output += "\nThe certificate has expired"
+	case t.criticalDays > 0 && days <= float64(t.criticalDays):
+		status = gogios.StatusFailed
+	case t.warningDays > 0 && days <= float64(t.warningDays):
+		status = gogios.StatusWarning
+	}
+
+	if verifyErr != nil {
+		status = gogios.StatusFailed
+		output += "\n" + verifyErr.Error()
+	}
+
+	result := checks.Result(status, output, perfdata...)
+	result.Expires = &expires
+
+	return result
+}
+
+// startTLS asks the server to switch to TLS in its own protocol
+func startTLS(conn net.Conn, protocol string) error {
+	switch protocol {
+	case "smtp":
+		text := textproto.NewConn(conn)
+		if _, _, err := text.ReadResponse(220); err != nil {
+			return err
+		}
+		if err := command(text, "EHLO gogios", 250); err != nil {
+			return err
+		}
+		return command(text, "STARTTLS", 220)
+	case "ftp":
+		text := textproto.NewConn(conn)
+		if _, _, err := text.ReadResponse(220); err != nil {
+			return err
+		}
+		return command(text, "AUTH TLS", 234)
+	case "imap":
+		text := textproto.NewConn(conn)
+		greeting, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(greeting, "* OK") {
+			return fmt.Errorf("unexpected greeting %q", greeting)
+		}
+		if err := text.PrintfLine("a1 STARTTLS"); err != nil {
+			return err
+		}
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 OK") {
+				return nil
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				return fmt.Errorf("server refused: %s", line)
+			}
+		}
+	case "postgres":
+		// An SSLRequest message, which the server answers with a single S
+		// if it supports TLS
+		var request [8]byte
+		binary.BigEndian.PutUint32(request[0:], 8)
+		binary.BigEndian.PutUint32(request[4:], 80877103)
+		if _, err := conn.Write(request[:]); err != nil {
+			return err
+		}
+		var reply [1]byte
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[0] != 'S' {
+			return fmt.Errorf("server does not support TLS")
+		}
+	}
+
+	return nil
+}
+
+// command sends a line and reads an SMTP or FTP style reply
+func command(text *textproto.Conn, line string, expectCode int) error {
+	if err := text.PrintfLine("%s", line); err != nil {
+		return err
+	}
+	_, _, err := text.ReadResponse(expectCode)
+
+	return err
+}
+
+func init() {
+	checks.Add("tls", func() gogios.Checker {
+		return &TLS{}
+	})
+}
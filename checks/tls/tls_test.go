@@ -0,0 +1,299 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/checks"
+)
+
+// server starts an HTTPS server and writes its certificate to a file that
+// can be used as ca_cert. The certificate is for example.com and 127.0.0.1
+func server(t *testing.T) (*httptest.Server, string) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	return srv, caCert
+}
+
+func run(t *testing.T, options string) gogios.CheckResult {
+	t.Helper()
+
+	checker, err := checks.New(checks.Spec{Type: "tls", Options: json.RawMessage(options)})
+	if err != nil {
+		t.Fatalf("Could not create the check, got error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return checker.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	srv, caCert := server(t)
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	base := fmt.Sprintf(`"host": %q, "port": %d`, addr.IP.String(), addr.Port)
+	trusted := base + fmt.Sprintf(`, "ca_cert": %q`, caCert)
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"trusted", `{` + trusted + `}`, gogios.StatusSuccess},
+		{"server name", `{` + trusted + `, "server_name": "example.com"}`, gogios.StatusSuccess},
+		{"wrong name", `{` + trusted + `, "server_name": "example.org"}`, gogios.StatusFailed},
+		{"untrusted", `{` + base + `}`, gogios.StatusFailed},
+		{"skip verify", `{` + base + `, "insecure_skip_verify": true}`, gogios.StatusSuccess},
+		{"expiring soon", `{` + trusted + `, "warning_days": 1000000}`, gogios.StatusWarning},
+		{"expiring very soon", `{` + trusted + `, "warning_days": 1000000, "critical_days": 1000000}`, gogios.StatusFailed},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+		if result.Expires == nil || !result.Expires.Equal(srv.Certificate().NotAfter) {
+			t.Errorf("%s: expected the expiry to be %s, got %v", test.name, srv.Certificate().NotAfter, result.Expires)
+		}
+	}
+}
+
+// issue makes a certificate for 127.0.0.1 that is valid until notAfter. It
+// is signed by parent, or by itself if parent is nil
+func issue(t *testing.T, name string, notAfter time.Time, ca bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-1000 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	if ca {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Could not create the certificate, got error: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return cert, key
+}
+
+// serveChain starts a TLS server on 127.0.0.1 that sends the certificates in
+// order, and returns its port
+func serveChain(t *testing.T, key *ecdsa.PrivateKey, certs ...*x509.Certificate) int {
+	t.Helper()
+
+	chain := tls.Certificate{PrivateKey: key}
+	for _, cert := range certs {
+		chain.Certificate = append(chain.Certificate, cert.Raw)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{chain}})
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TestThresholds runs the check against a certificate that expires in three
+// days and a half
+func TestThresholds(t *testing.T) {
+	cert, key := issue(t, "127.0.0.1", time.Now().Add(84*time.Hour), false, nil, nil)
+	port := serveChain(t, key, cert)
+
+	base := fmt.Sprintf(`"host": "127.0.0.1", "port": %d, "insecure_skip_verify": true`, port)
+	tests := []struct {
+		name    string
+		options string
+		status  string
+	}{
+		{"defaults", `{` + base + `}`, gogios.StatusFailed},
+		{"critical off", `{` + base + `, "critical_days": 0}`, gogios.StatusWarning},
+		{"both off", `{` + base + `, "warning_days": 0, "critical_days": 0}`, gogios.StatusSuccess},
+		{"warning off", `{` + base + `, "warning_days": 0, "critical_days": 2}`, gogios.StatusSuccess},
+		{"lower critical", `{` + base + `, "critical_days": 2}`, gogios.StatusWarning},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+	}
+}
+
+// TestChain sends an expired certificate along with the chain, the way some
+// servers still send old cross-signs, and checks that only the verified chain
+// decides the expiry
+func TestChain(t *testing.T) {
+	now := time.Now()
+	root, rootKey := issue(t, "Root", now.Add(3000*24*time.Hour), true, nil, nil)
+	intermediate, intermediateKey := issue(t, "Intermediate", now.Add(100*24*time.Hour), true, root, rootKey)
+	leaf, leafKey := issue(t, "127.0.0.1", now.Add(300*24*time.Hour), false, intermediate, intermediateKey)
+	stale, _ := issue(t, "Old cross-sign", now.Add(-24*time.Hour), true, nil, nil)
+	port := serveChain(t, leafKey, leaf, intermediate, stale)
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0600); err != nil {
+		t.Fatalf("Could not write the CA, got error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		status  string
+		expires time.Time
+	}{
+		{"verified", fmt.Sprintf(`{"host": "127.0.0.1", "port": %d, "ca_cert": %q}`, port, caCert), gogios.StatusSuccess, intermediate.NotAfter},
+		{"skip verify", fmt.Sprintf(`{"host": "127.0.0.1", "port": %d, "insecure_skip_verify": true}`, port), gogios.StatusSuccess, leaf.NotAfter},
+		{"untrusted", fmt.Sprintf(`{"host": "127.0.0.1", "port": %d}`, port), gogios.StatusFailed, leaf.NotAfter},
+	}
+
+	for _, test := range tests {
+		result := run(t, test.options)
+		if result.Status != test.status {
+			t.Errorf("%s: expected %s, got %s: %s", test.name, test.status, result.Status, result.Output)
+		}
+		if result.Expires == nil || !result.Expires.Equal(test.expires) {
+			t.Errorf("%s: expected the expiry to be %s, got %v", test.name, test.expires, result.Expires)
+		}
+	}
+}
+
+// TestStartTLS runs the check against servers that need to be asked to start
+// TLS first
+func TestStartTLS(t *testing.T) {
+	srv, caCert := server(t)
+	config := &tls.Config{Certificates: srv.TLS.Certificates}
+
+	dialogs := map[string]func(conn net.Conn) bool{
+		"smtp": func(conn net.Conn) bool {
+			io.WriteString(conn, "220-mail.example.com ESMTP\r\n220 ready\r\n")
+			return expect(conn, "EHLO gogios\r\n") &&
+				write(conn, "250-mail.example.com\r\n250 STARTTLS\r\n") &&
+				expect(conn, "STARTTLS\r\n") &&
+				write(conn, "220 go ahead\r\n")
+		},
+		"ftp": func(conn net.Conn) bool {
+			io.WriteString(conn, "220 ftp ready\r\n")
+			return expect(conn, "AUTH TLS\r\n") && write(conn, "234 go ahead\r\n")
+		},
+		"imap": func(conn net.Conn) bool {
+			io.WriteString(conn, "* OK IMAP ready\r\n")
+			return expect(conn, "a1 STARTTLS\r\n") && write(conn, "a1 OK begin TLS\r\n")
+		},
+		"postgres": func(conn net.Conn) bool {
+			return expect(conn, "\x00\x00\x00\x08\x04\xd2\x16\x2f") && write(conn, "S")
+		},
+	}
+
+	for protocol, dialog := range dialogs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Could not listen, got error: %s", err)
+		}
+		defer listener.Close()
+
+		go func(dialog func(net.Conn) bool) {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if dialog(conn) {
+				tls.Server(conn, config).Handshake()
+			}
+		}(dialog)
+
+		options := fmt.Sprintf(`{"host": "127.0.0.1", "port": %d, "starttls": %q, "ca_cert": %q}`, listener.Addr().(*net.TCPAddr).Port, protocol, caCert)
+		result := run(t, options)
+		if result.Status != gogios.StatusSuccess {
+			t.Errorf("%s: expected success, got %s: %s", protocol, result.Status, result.Output)
+		}
+	}
+}
+
+func expect(conn net.Conn, want string) bool {
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		return false
+	}
+
+	return string(got) == want
+}
+
+func write(conn net.Conn, s string) bool {
+	_, err := io.WriteString(conn, s)
+	return err == nil
+}
+
+func TestOptions(t *testing.T) {
+	bad := []string{
+		`{"port": 443}`,
+		`{"host": "example.com", "starttls": "xmpp"}`,
+		`{"host": "example.com", "ca_cert": "/nonexistent"}`,
+		`{"host": "example.com", "warning_days": -1}`,
+	}
+
+	for _, options := range bad {
+		if _, err := checks.New(checks.Spec{Type: "tls", Options: json.RawMessage(options)}); err == nil {
+			t.Errorf("Options %s should have been rejected", options)
+		}
+	}
+
+	checker, _ := checks.New(checks.Spec{Type: "tls", Options: json.RawMessage(`{"host": "example.com", "starttls": "SMTP"}`)})
+	if tls := checker.(*TLS); tls.address != "example.com:25" || !strings.EqualFold(tls.StartTLS, "smtp") {
+		t.Errorf("Expected the SMTP port by default, got %s", tls.address)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/manager"
+	"github.com/bkasin/gogios/helpers/config"
+	"github.com/bkasin/gogios/helpers/models"
+)
+
+// dbCommand implements `gogios db`, which manages the schemas and contents of
+// the configured databases and returns the exit code for the process
+func dbCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("db", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gogios [-config file] db <command>\n\nCommands:\n")
+		fmt.Fprintf(stderr, "  migrate\tApply pending schema migrations\n")
+		fmt.Fprintf(stderr, "  status\tShow the schema migrations and whether they have been applied\n")
+		fmt.Fprintf(stderr, "  backfill\tCopy checks, history and users from one database into another\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var run func(context.Context, gogios.Migrator, io.Writer) error
+	switch fs.Arg(0) {
+	case "migrate":
+		run = dbMigrate
+	case "status":
+		run = dbStatus
+	case "backfill":
+		return dbBackfill(fs.Args()[1:], stdout, stderr)
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	if len(config.Conf.Databases) == 0 {
+		fmt.Fprintln(stderr, "db: no databases are configured")
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, database := range config.Conf.Databases {
+		fmt.Fprintf(stdout, "%s:\n", database.LogName())
+		if err := runOnDatabase(database, run, stdout); err != nil {
+			fmt.Fprintf(stdout, "  failed: %s\n", err.Error())
+			code = exitFailed
+		}
+	}
+
+	return code
+}
+
+// runOnDatabase opens a database, runs f against it if it has a versioned
+// schema and closes it again
+func runOnDatabase(database *models.ActiveDatabase, f func(context.Context, gogios.Migrator, io.Writer) error, stdout io.Writer) error {
+	migrator, ok := database.Database.(gogios.Migrator)
+	if !ok {
+		fmt.Fprintln(stdout, "  no versioned schema")
+		return nil
+	}
+
+	if err := database.Database.Init(); err != nil {
+		return err
+	}
+	defer database.Database.Close()
+
+	return f(context.Background(), migrator, stdout)
+}
+
+func dbMigrate(ctx context.Context, migrator gogios.Migrator, stdout io.Writer) error {
+	ran, err := migrator.Migrate(ctx)
+	for _, status := range ran {
+		fmt.Fprintf(stdout, "  applied %d: %s\n", status.Version, status.Description)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(ran) == 0 {
+		fmt.Fprintln(stdout, "  already up to date")
+	}
+
+	return nil
+}
+
+func dbStatus(ctx context.Context, migrator gogios.Migrator, stdout io.Writer) error {
+	statuses, err := migrator.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format(time.RFC822)
+		}
+		if status.Unknown {
+			state += " (unknown to this version of gogios)"
+		}
+		fmt.Fprintf(stdout, "  %4d  %-45s  %s\n", status.Version, status.Description, state)
+	}
+
+	return nil
+}
+
+// dbBackfill implements `gogios db backfill`, which copies everything from one
+// configured database into another, usually one that was just added
+func dbBackfill(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("db backfill", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "Name or alias of the database to copy from (default: the first one)")
+	to := fs.String("to", "", "Name or alias of the database to copy into")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gogios [-config file] db backfill [-from name] -to name\n\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *to == "" {
+		fs.Usage()
+		return exitUsage
+	}
+
+	source, err := selectDatabase(config.Conf.Databases, *from)
+	if err != nil {
+		fmt.Fprintf(stderr, "db backfill: %s\n", err.Error())
+		return exitUsage
+	}
+	target, err := selectDatabase(config.Conf.Databases, *to)
+	if err != nil {
+		fmt.Fprintf(stderr, "db backfill: %s\n", err.Error())
+		return exitUsage
+	}
+	if source == target {
+		fmt.Fprintln(stderr, "db backfill: -from and -to are the same database")
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	for _, database := range []*models.ActiveDatabase{source, target} {
+		if err := database.Database.Init(); err != nil {
+			fmt.Fprintf(stdout, "%s: failed: %s\n", database.LogName(), err.Error())
+			return exitFailed
+		}
+		defer database.Database.Close()
+
+		if migrator, ok := database.Database.(gogios.Migrator); ok {
+			if _, err := migrator.Migrate(ctx); err != nil {
+				fmt.Fprintf(stdout, "%s: failed: %s\n", database.LogName(), err.Error())
+				return exitFailed
+			}
+		}
+	}
+
+	stats, err := manager.Backfill(ctx, source.Database, target.Database)
+	fmt.Fprintf(stdout, "Copied %d users, %d checks with %d history rows and %d rollups from %s to %s. %d checks already existed\n",
+		stats.Users, stats.Checks, stats.History, stats.Rollups, source.LogName(), target.LogName(), stats.Skipped)
+	if err != nil {
+		fmt.Fprintf(stdout, "failed: %s\n", err.Error())
+		return exitFailed
+	}
+
+	return exitOK
+}
+
+// selectDatabase finds a database by name or alias. An empty name selects the
+// first one
+func selectDatabase(all []*models.ActiveDatabase, name string) (*models.ActiveDatabase, error) {
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no databases are configured")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return all[0], nil
+	}
+
+	var found *models.ActiveDatabase
+	for _, database := range all {
+		if database.Config.Name == name || (database.Config.Alias != "" && database.Config.Alias == name) {
+			if found != nil {
+				return nil, fmt.Errorf("%q matches more than one database, use an alias", name)
+			}
+			found = database
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no database is named %q", name)
+	}
+
+	return found, nil
+}
@@ -1,20 +1,30 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/bkasin/gogios"
 	"github.com/bkasin/gogios/api"
+	"github.com/bkasin/gogios/checks"
+	_ "github.com/bkasin/gogios/checks/all"
 	_ "github.com/bkasin/gogios/databases/all"
 	"github.com/bkasin/gogios/helpers"
 	"github.com/bkasin/gogios/helpers/config"
+	"github.com/bkasin/gogios/helpers/metrics"
+	"github.com/bkasin/gogios/helpers/retention"
+	"github.com/bkasin/gogios/helpers/scheduler"
 	_ "github.com/bkasin/gogios/notifiers/all"
+	_ "github.com/bkasin/gogios/outputs/all"
 	"github.com/bkasin/gogios/setup"
 	"github.com/bkasin/gogios/web"
 	"github.com/google/logger"
@@ -23,10 +33,21 @@ import (
 var (
 	configFile = flag.String("config", "/etc/gogios/gogios.toml", "Config file to use")
 	sampleConf = flag.Bool("sample_conf", false, "Print a sample config file to stdout")
-	notify     = flag.String("notify", "", "Send a message to all notifiers")
+	notify     = flag.String("notify", "", "Send a message to all notifiers (shorthand for the notify subcommand)")
+
+	// pool runs the checks of every round, so rounds that overlap share its limit
+	pool *scheduler.Pool
 )
 
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: gogios [flags] [command]\n\nCommands:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  notify\tSend a message or test event to some or all notifiers\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  db\tMigrate database schemas or backfill a new database\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
 	if *sampleConf {
@@ -56,16 +77,21 @@ func main() {
 	initialLogger.Infoln(config.Conf.DatabaseNames())
 	initialLogger.Infoln(config.Conf.NotifierNames())
 
-	// If -notify is set, then send the message and exit before checking databases
+	// Subcommands and -notify run and exit before checking databases
+	switch flag.Arg(0) {
+	case "":
+	case "notify":
+		os.Exit(notifyCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "db":
+		os.Exit(dbCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
 	if *notify != "" {
-		for _, notifier := range config.Conf.Notifiers {
-			err := notifier.Notifier.Notify("External Message", time.Now().Format(time.RFC822), *notify, "Send")
-			if err != nil {
-				initialLogger.Errorln(err.Error())
-				os.Exit(1)
-			}
-		}
-		os.Exit(1)
+		legacyNotify(*notify)
 	}
 
 	// Need at least one database to start
@@ -82,6 +108,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Release database connections when asked to stop
+	go closeOnSignal(initialLogger)
+
+	// Watch the health of the databases and replay writes that were missed
+	go config.DB.Run(context.Background(), config.Conf.Options.HealthCheckInterval.Duration)
+
+	// Roll up and prune old history in the background
+	go retention.Run(context.Background(), config.Conf.Databases, retentionPolicy(), config.Conf.Options.PruneInterval.Duration, initialLogger)
+
+	// Limit how many checks run at once
+	pool = scheduler.New(config.Conf.Options.MaxConcurrentChecks)
+
 	// Set the PATH that will be used by checks
 	os.Setenv("PATH", "/bin:/usr/bin:/usr/local/bin:/usr/lib/gogios/plugins")
 
@@ -126,35 +164,70 @@ func runChecks(t time.Time) {
 		os.Exit(1)
 	}
 
-	// Use the first configured database as the primary for holding data
-	primaryDB := config.Conf.Databases[0].Database
-	allPrev, err := primaryDB.GetAllChecks()
+	// The type and options of the checks that do not run a command
+	var specs []checks.Spec
+	err = json.Unmarshal(raw, &specs)
+	if err != nil {
+		checkLogger.Errorf("JSON could not be unmarshaled, error return:\n%s", err.Error())
+		os.Exit(1)
+	}
+
+	// Reads come from the first healthy database, writes go to all of them
+	ctx := context.Background()
+	roundStart := time.Now()
+	allPrev, err := config.DB.GetAllChecks(ctx)
 	if err != nil {
 		checkLogger.Errorf("Could not read database, error return:\n%s", err.Error())
 	}
 
 	var wg sync.WaitGroup
-	wg.Add(len(curr))
+	ran := make([]bool, len(curr))
 
-	// Iterate through all the checks in the check list
+	// Queue every check in the check list. The pool decides how many run at
+	// once, and skips checks that are still running from the previous round
 	for i := 0; i < len(curr); i++ {
-		go func(i int) {
+		i := i
+		wg.Add(1)
+		ran[i] = pool.Submit(curr[i].Title, func() {
 			defer wg.Done()
 			curr[i].Status = "Failed"
 
-			outputChannel := make(chan string, 1)
+			timeout, err := curr[i].RunTimeout(config.Conf.Options.Timeout.Duration)
+			if err != nil {
+				checkLogger.Errorf("%s, using the default of %s", err.Error(), timeout)
+			}
+
+			// The command and everything it started are killed once the timeout passes
+			runCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			// A check whose options are wrong fails without running
+			checker, specErr := checks.New(specs[i])
+			if specErr != nil {
+				checkLogger.Errorf("Check %s is misconfigured: %s", curr[i].Title, specErr.Error())
+			}
+
+			start := time.Now()
+			outputChannel := make(chan gogios.CheckResult, 1)
 			go func() {
-				commandReturn := check(checkLogger, curr[i])
-				outputChannel <- commandReturn
+				if specErr != nil {
+					outputChannel <- checks.Failed(specErr)
+					return
+				}
+				outputChannel <- check(runCtx, checkLogger, curr[i], checker)
 			}()
 
 			var goodCount = 0
 			// Start at 1 because newly added checks will start as 1/0 or 0/0 otherwise
 			var totalCount = 1
 
-			prev, err := primaryDB.GetCheck(curr[i].Title, "title")
-			if err != nil {
-				checkLogger.Errorf("Could not read database into prev variable, error return:\n%s", err.Error())
+			// A check that is not in the database yet is new. Any other error means
+			// the previous state is unknown, so the result can not be stored
+			stored := true
+			prev, err := config.DB.GetCheck(ctx, curr[i].Title, "title")
+			if err != nil && !errors.Is(err, gogios.ErrNotFound) {
+				checkLogger.Errorf("Could not read database into prev variable, result of %s will not be stored. Error return:\n%s", curr[i].Title, err.Error())
+				stored = false
 			}
 
 			if prev.Title != "" {
@@ -162,28 +235,33 @@ func runChecks(t time.Time) {
 				totalCount = prev.TotalCount + 1
 			}
 
-			var Output string
-			select {
-			case output := <-outputChannel:
-				if strings.Contains(output, curr[i].Expected) {
-					curr[i].Status = "Success"
-					goodCount++
-				}
-				Output = output
-			case <-time.After(config.Conf.Options.Timeout.Duration):
-				curr[i].Status = "Timed Out"
+			// A check that timed out keeps whatever it printed before it was killed
+			result := <-outputChannel
+			curr[i].Status = result.Status
+			if gogios.Severity(result.Status) < gogios.SeverityCritical {
+				goodCount++
 			}
+			curr[i].ExitCode = result.ExitCode
+			curr[i].Perfdata = result.Perfdata
+			curr[i].Expires = result.Expires
+			Output := gogios.NewCheckOutput(result.FullOutput(), result.Stderr)
 
 			curr[i].Asof = time.Now()
+			curr[i].Duration = curr[i].Asof.Sub(start)
 			curr[i].GoodCount = goodCount
 			curr[i].TotalCount = totalCount
 
-			// Send out notifications through all enabled notifiers
-			if prev.Title != "" && curr[i].Status != prev.Status {
+			// Send out notifications through all enabled notifiers that want this check
+			if stored && prev.Title != "" && curr[i].Status != prev.Status {
 				for _, notifier := range config.Conf.Notifiers {
-					err := notifier.Notifier.Notify(curr[i].Title, curr[i].Asof.Format(time.RFC822), Output, curr[i].Status)
+					if !notifier.Accepts(curr[i].Title, curr[i].Status, prev.Status) {
+						continue
+					}
+
+					err := notifier.Notify(curr[i].Title, curr[i].Asof.Format(time.RFC822), Output.Stdout+Output.Stderr, curr[i].Status)
 					if err != nil {
-						checkLogger.Errorln(err.Error())
+						checkLogger.Errorf("Notifier %s failed: %s", notifier.LogName(), err.Error())
+						metrics.NotifierError(notifier.LogName())
 					}
 				}
 			}
@@ -192,52 +270,134 @@ func runChecks(t time.Time) {
 			// GORM will assign a new ID if prev.ID is nil
 			curr[i].ID = prev.ID
 
-			// Update or add rows for each configured database, then remove from allPrev[]
-			for _, database := range config.Conf.Databases {
-				err := database.Database.AddCheck(curr[i], Output)
+			// Update or add rows in every configured database
+			if stored {
+				err := config.DB.AddCheck(ctx, curr[i], Output)
 				if err != nil {
-					checkLogger.Errorln(err.Error())
-				}
-
-				for old := 0; old < len(allPrev); old++ {
-					if allPrev[old].ID == curr[i].ID {
-						allPrev = append(allPrev[:old], allPrev[old+1:]...)
-					}
+					checkLogger.Errorf("Could not store the result of %s: %s", curr[i].Title, err.Error())
 				}
 			}
 
 			checkLogger.Infof("Check %s status: %s as of: %s\n", curr[i].Title, curr[i].Status, curr[i].Asof.Format(time.RFC822))
 			if config.Conf.Options.Verbose {
-				checkLogger.Infof("Output: \n%s", Output)
+				checkLogger.Infof("Exit code: %d\nStdout: \n%s\nStderr: \n%s", curr[i].ExitCode, Output.Stdout, Output.Stderr)
 			}
 
 			web.UpdateWebData()
-		}(i)
+		})
+		if !ran[i] {
+			wg.Done()
+			checkLogger.Warningf("Check %s is still running from an earlier round, skipping it this round", curr[i].Title)
+		}
+	}
+	if queued := pool.Queued(); queued > 0 {
+		checkLogger.Infof("%d checks are waiting for one of %d slots", queued, config.Conf.Options.MaxConcurrentChecks)
 	}
 
 	wg.Wait()
+	metrics.ObserveRound(time.Since(roundStart))
+
+	var finished []gogios.Check
+	for i := 0; i < len(curr); i++ {
+		if ran[i] {
+			finished = append(finished, curr[i])
+		}
+	}
+
+	// Export the results of this round to every output
+	for _, output := range config.Conf.Outputs {
+		err := output.Write(ctx, finished)
+		if err != nil {
+			checkLogger.Errorf("Output %s failed: %s", output.LogName(), err.Error())
+			metrics.OutputError(output.LogName())
+		}
+	}
+
+	// Delete the checks that are no longer in the check list from the database
+	current := make(map[string]bool, len(curr))
+	for i := 0; i < len(curr); i++ {
+		current[curr[i].Title] = true
+	}
 
-	// Delete whatever is left in allPrev from the database
 	for i := 0; i < len(allPrev); i++ {
-		for _, database := range config.Conf.Databases {
-			err := database.Database.DeleteCheck(allPrev[i], "id")
-			if err != nil {
-				checkLogger.Errorln(err.Error())
-			}
+		if current[allPrev[i].Title] {
+			continue
+		}
+
+		err := config.DB.DeleteCheck(ctx, allPrev[i], "title")
+		if err != nil && !errors.Is(err, gogios.ErrNotFound) {
+			checkLogger.Errorf("Could not remove %s: %s", allPrev[i].Title, err.Error())
 		}
 	}
 }
 
-// doEvery - Run function f every d length of time
+// closeOnSignal waits for SIGINT or SIGTERM and then closes the databases
+// before exiting
+func closeOnSignal(logger *logger.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signals
+	logger.Infof("Received %s, shutting down", sig)
+
+	if err := config.ClosePlugins(); err != nil {
+		logger.Errorln(err.Error())
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// retentionPolicy converts the configured retention days into a policy
+func retentionPolicy() retention.Policy {
+	day := 24 * time.Hour
+	return retention.Policy{
+		Raw:    time.Duration(config.Conf.Options.RawHistoryDays) * day,
+		Hourly: time.Duration(config.Conf.Options.HourlyHistoryDays) * day,
+		Daily:  time.Duration(config.Conf.Options.DailyHistoryDays) * day,
+	}
+}
+
+// doEvery - Run function f every d length of time. A round that runs long
+// does not hold up the next one
 func doEvery(d time.Duration, f func(time.Time)) {
 	for x := range time.Tick(d) {
-		f(x)
+		go f(x)
 	}
 }
 
-func check(logger *logger.Logger, check gogios.Check) string {
+// check runs a check once, either through its built in type or as a command
+// in sh
+func check(ctx context.Context, logger *logger.Logger, check gogios.Check, checker gogios.Checker) gogios.CheckResult {
+	if checker != nil {
+		// Whatever went wrong once the time is up, the check ran out of time
+		result := checker.Run(ctx)
+		if ctx.Err() != nil && result.Status != gogios.StatusSuccess {
+			result.Status = gogios.StatusTimedOut
+			result.ExitCode = -1
+		}
+		return result
+	}
+
 	var args = []string{"-c", check.Command}
-	var output = helpers.GetCommandOutput(logger, "/bin/sh", args)
+	command := helpers.RunCommand(ctx, logger, "/bin/sh", args)
+
+	result := gogios.CheckResult{Status: gogios.StatusFailed, Output: command.Stdout, Stderr: command.Stderr, ExitCode: command.ExitCode}
+	if command.TimedOut {
+		result.Status = gogios.StatusTimedOut
+	} else if matches(command, check.Expected) {
+		result.Status = gogios.StatusSuccess
+	}
+
+	return result
+}
+
+// matches reports whether a run printed the expected text on stdout or
+// stderr. A command that exits with an error counts as having printed nothing
+func matches(result helpers.CommandResult, expected string) bool {
+	if result.ExitCode != 0 {
+		return expected == ""
+	}
 
-	return output
+	return strings.Contains(result.Stdout, expected) || strings.Contains(result.Stderr, expected)
 }
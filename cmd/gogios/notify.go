@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios/helpers/config"
+	"github.com/bkasin/gogios/helpers/models"
+)
+
+// Exit codes used by subcommands
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// Defaults for the synthetic event sent by `gogios notify -test`
+const (
+	testCheck   = "Gogios Test Check"
+	testMessage = "This is a test notification sent by gogios"
+)
+
+// notifyResult is the outcome of sending to a single notifier
+type notifyResult struct {
+	Name string
+	Err  error
+}
+
+// notifyCommand implements `gogios notify`. It sends a message, or a synthetic
+// state change when -test is given, to every notifier or only the ones named
+// with -to, and returns the exit code for the process.
+func notifyCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("notify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	to := fs.String("to", "", "Comma separated list of notifier names or aliases to send to (default: all)")
+	test := fs.Bool("test", false, "Send a synthetic state change event instead of a plain message")
+	check := fs.String("check", testCheck, "Check title used by -test")
+	status := fs.String("status", "Failed", "Status used by -test")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gogios [-config file] notify [-to names] [-test [-check title] [-status status]] [message]\n\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	message := strings.Join(fs.Args(), " ")
+	if message == "" && !*test {
+		fmt.Fprintln(stderr, "notify: a message is required unless -test is set")
+		fs.Usage()
+		return exitUsage
+	}
+
+	targets, err := selectNotifiers(config.Conf.Notifiers, *to)
+	if err != nil {
+		fmt.Fprintf(stderr, "notify: %s\n", err.Error())
+		return exitUsage
+	}
+
+	title, state := "External Message", "Send"
+	if *test {
+		title, state = *check, *status
+		if message == "" {
+			message = testMessage
+		}
+	}
+
+	results := sendNotifications(targets, title, time.Now().Format(time.RFC822), message, state)
+
+	code := exitOK
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(stdout, "%s: failed: %s\n", result.Name, result.Err.Error())
+			code = exitFailed
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok\n", result.Name)
+	}
+
+	return code
+}
+
+// selectNotifiers returns the notifiers whose name or alias appears in the
+// comma separated list. An empty list selects every configured notifier.
+func selectNotifiers(all []*models.ActiveNotifier, list string) ([]*models.ActiveNotifier, error) {
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no notifiers are configured")
+	}
+	if strings.TrimSpace(list) == "" {
+		return all, nil
+	}
+
+	var selected []*models.ActiveNotifier
+	for _, want := range strings.Split(list, ",") {
+		want = strings.TrimSpace(want)
+		if want == "" {
+			continue
+		}
+
+		found := false
+		for _, notifier := range all {
+			if notifier.Config.Name == want || (notifier.Config.Alias != "" && notifier.Config.Alias == want) {
+				selected = append(selected, notifier)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no notifier named %q is configured", want)
+		}
+	}
+
+	return selected, nil
+}
+
+// sendNotifications initializes and sends to each notifier in turn, collecting
+// a result for every one of them instead of stopping at the first failure
+func sendNotifications(targets []*models.ActiveNotifier, check, asof, output, status string) []notifyResult {
+	var results []notifyResult
+
+	for _, notifier := range targets {
+		err := notifier.Notifier.Init()
+		if err == nil {
+			err = notifier.Notify(check, asof, output, status)
+		}
+
+		results = append(results, notifyResult{Name: notifier.LogName(), Err: err})
+	}
+
+	return results
+}
+
+// legacyNotify keeps the old -notify flag working as a shorthand for
+// `gogios notify <message>`
+func legacyNotify(message string) {
+	os.Exit(notifyCommand([]string{"--", message}, os.Stdout, os.Stderr))
+}
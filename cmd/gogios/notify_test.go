@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bkasin/gogios/helpers/config"
+	"github.com/bkasin/gogios/helpers/models"
+)
+
+// fakeNotifier records what it was sent, and fails if err is set
+type fakeNotifier struct {
+	err  error
+	sent []string
+}
+
+func (f *fakeNotifier) SampleConfig() string { return "" }
+func (f *fakeNotifier) SubConfig() string    { return "" }
+func (f *fakeNotifier) Description() string  { return "fake" }
+func (f *fakeNotifier) Init() error          { return nil }
+
+func (f *fakeNotifier) Notify(check, time, output, status string) error {
+	f.sent = append(f.sent, check+"|"+status+"|"+output)
+	return f.err
+}
+
+func fakes() []*models.ActiveNotifier {
+	return []*models.ActiveNotifier{
+		models.NewActiveNotifier(&fakeNotifier{}, &models.NotifierConfig{Name: "slack", Alias: "ops"}),
+		models.NewActiveNotifier(&fakeNotifier{}, &models.NotifierConfig{Name: "twilio"}),
+		models.NewActiveNotifier(&fakeNotifier{}, &models.NotifierConfig{Name: "slack", Alias: "dev"}),
+	}
+}
+
+func TestSelectNotifiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		list  string
+		names []string
+		err   bool
+	}{
+		{"all", "", []string{"slack (ops)", "twilio", "slack (dev)"}, false},
+		{"blank", " ", []string{"slack (ops)", "twilio", "slack (dev)"}, false},
+		{"name", "twilio", []string{"twilio"}, false},
+		{"name with aliases", "slack", []string{"slack (ops)", "slack (dev)"}, false},
+		{"alias", "dev", []string{"slack (dev)"}, false},
+		{"list", "ops, twilio,", []string{"slack (ops)", "twilio"}, false},
+		{"unknown", "ops,email", nil, true},
+	}
+
+	for _, test := range tests {
+		selected, err := selectNotifiers(fakes(), test.list)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t, got %v", test.name, test.err, err)
+			continue
+		}
+
+		var names []string
+		for _, notifier := range selected {
+			names = append(names, notifier.LogName())
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.names, names)
+		}
+	}
+
+	if _, err := selectNotifiers(nil, ""); err == nil {
+		t.Error("Selecting with no notifiers configured should fail")
+	}
+}
+
+func TestNotifyCommand(t *testing.T) {
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+
+	tests := []struct {
+		name   string
+		args   []string
+		fail   bool
+		code   int
+		output []string
+	}{
+		{"message", []string{"hello", "world"}, false, exitOK, []string{"slack (ops): ok", "twilio: ok", "slack (dev): ok"}},
+		{"test event", []string{"-to", "twilio", "-test"}, false, exitOK, []string{"twilio: ok"}},
+		{"failure", []string{"-to", "twilio", "hello"}, true, exitFailed, []string{"twilio: failed: no signal"}},
+		{"no message", []string{"-to", "twilio"}, false, exitUsage, nil},
+		{"unknown notifier", []string{"-to", "email", "hello"}, false, exitUsage, nil},
+		{"bad flag", []string{"-nope", "hello"}, false, exitUsage, nil},
+	}
+
+	for _, test := range tests {
+		notifiers := fakes()
+		if test.fail {
+			notifiers[1].Notifier.(*fakeNotifier).err = errors.New("no signal")
+		}
+		config.Conf = &config.Config{Notifiers: notifiers}
+
+		var stdout, stderr bytes.Buffer
+		code := notifyCommand(test.args, &stdout, &stderr)
+		if code != test.code {
+			t.Errorf("%s: expected exit code %d, got %d: %s%s", test.name, test.code, code, stdout.String(), stderr.String())
+		}
+
+		var lines []string
+		if out := strings.TrimSpace(stdout.String()); out != "" {
+			lines = strings.Split(out, "\n")
+		}
+		if !reflect.DeepEqual(lines, test.output) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.output, lines)
+		}
+	}
+
+	// -test sends a synthetic state change with the default check and status
+	notifiers := fakes()
+	config.Conf = &config.Config{Notifiers: notifiers}
+	notifyCommand([]string{"-to", "dev", "-test", "-status", "Warning"}, &bytes.Buffer{}, &bytes.Buffer{})
+	want := []string{testCheck + "|Warning|" + testMessage}
+	if sent := notifiers[2].Notifier.(*fakeNotifier).sent; !reflect.DeepEqual(sent, want) {
+		t.Errorf("Expected %q to be sent, got %q", want, sent)
+	}
+	if sent := notifiers[0].Notifier.(*fakeNotifier).sent; sent != nil {
+		t.Errorf("Only the selected notifier should be sent to, slack (ops) got %q", sent)
+	}
+}
@@ -1,7 +1,11 @@
 package gogios
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jinzhu/gorm"
 )
@@ -26,6 +30,55 @@ type Check struct {
 	GoodCount  int       `json:"good_count"`  // The total number of times that this check has succeeded
 	TotalCount int       `json:"total_count"` // The total number of times that this check has run
 	Asof       time.Time `json:"asof"`        // Datetime that the most recent check finished at
+
+	Duration time.Duration `json:"duration"`  // How long the most recent run took
+	ExitCode int           `json:"exit_code"` // Exit code of the most recent run, -1 if it did not exit on its own
+
+	// When the certificate seen by the most recent run expires. Only set by
+	// tls checks
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Measurements from the most recent run, passed on to outputs. They are
+	// kept in the history output rather than in a column of their own
+	Perfdata []Perfdata `gorm:"-" json:"perfdata,omitempty"`
+
+	// How long the check may run before it is killed, such as "30s". Read from
+	// the check list only; the timeout option is used when it is empty
+	Timeout string `gorm:"-" json:"timeout,omitempty"`
+
+	// Comma separated tags to group checks by, such as "web,production". They
+	// are exported as a label on the check's metrics
+	Tags string `gorm:"size:255" json:"tags,omitempty"`
+}
+
+// TagList returns the check's tags with blanks and surrounding spaces removed
+func (c Check) TagList() []string {
+	var tags []string
+	for _, tag := range strings.Split(c.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// RunTimeout returns how long the check may run, falling back to def when it
+// does not set a valid timeout of its own
+func (c Check) RunTimeout(def time.Duration) (time.Duration, error) {
+	if c.Timeout == "" {
+		return def, nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return def, fmt.Errorf("check %q: invalid timeout %q: %w", c.Title, c.Timeout, err)
+	}
+	if timeout <= 0 {
+		return def, fmt.Errorf("check %q: timeout must be positive, got %q", c.Title, c.Timeout)
+	}
+
+	return timeout, nil
 }
 
 // CheckHistory - stores the historical returns of each check that runs
@@ -34,26 +87,126 @@ type CheckHistory struct {
 
 	CheckID *uint      `gorm:"ForeignKey:ID"`      // Foreign key. The ID of the check
 	Asof    *time.Time `json:"asof"`               // Datetime that the check finished at
-	Output  string     `gorm:"type:varchar(1250)"` // The output of the command that was run
+	Output  string     `gorm:"type:varchar(1250)"` // What the command that was run printed to stdout
 	Status  *string    // The exit status of that check. Success, Failed, Timed Out
+
+	Duration  time.Duration `json:"duration"`                         // How long the check took to run
+	ExitCode  int           `json:"exit_code"`                        // Exit code of the command, -1 if it did not exit on its own
+	Stderr    string        `gorm:"type:varchar(1250)" json:"stderr"` // What the command printed to stderr
+	Truncated bool          `json:"truncated"`                        // Output or Stderr was cut to MaxOutputLength
 }
 
-// Database object declaration
+// MaxOutputLength is the most of stdout and of stderr that is kept for each
+// run, matching the size of the history columns
+const MaxOutputLength = 1250
+
+// CheckOutput is what one run of a check printed
+type CheckOutput struct {
+	Stdout    string
+	Stderr    string
+	Truncated bool
+}
+
+// NewCheckOutput cuts stdout and stderr down to MaxOutputLength bytes and
+// records whether anything was lost
+func NewCheckOutput(stdout, stderr string) CheckOutput {
+	stdout, cutStdout := truncate(stdout)
+	stderr, cutStderr := truncate(stderr)
+
+	return CheckOutput{Stdout: stdout, Stderr: stderr, Truncated: cutStdout || cutStderr}
+}
+
+// truncate shortens s to at most MaxOutputLength bytes without splitting a
+// UTF-8 character
+func truncate(s string) (string, bool) {
+	if len(s) <= MaxOutputLength {
+		return s, false
+	}
+
+	end := MaxOutputLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return s[:end], true
+}
+
+// Periods that history is rolled up into once the raw rows are pruned
+const (
+	RollupHour = "hour"
+	RollupDay  = "day"
+)
+
+// CheckHistoryRollup - aggregated history of a check over an hour or a day,
+// which replaces the raw CheckHistory rows once they are pruned
+type CheckHistoryRollup struct {
+	gorm.Model
+
+	CheckID     uint          `gorm:"index"`              // The ID of the check
+	Period      string        `gorm:"size:10;index"`      // RollupHour or RollupDay
+	Start       time.Time     `gorm:"index" json:"start"` // Beginning of the hour or day, in UTC
+	Up          int           `json:"up"`                 // Runs that ended in Success or Warning
+	Down        int           `json:"down"`               // Runs that ended in any other status
+	AvgDuration time.Duration `json:"avg_duration"`       // Average run duration
+}
+
+// Database is implemented by every storage backend. Every method that touches
+// storage takes a context and reports failures instead of returning zero
+// values; lookups that find nothing return an error wrapping ErrNotFound.
 type Database interface {
 	SampleConfig() string
 	SubConfig() string
 
 	Description() string
 
-	AddCheck(check Check, output string) error
-	DeleteCheck(check Check, field string) error
-	GetCheck(searchField, searchType string) (Check, error)
-	GetAllChecks() ([]Check, error)
-	GetCheckHistory(check Check, amount int) ([]CheckHistory, error)
-	AddUser(user User) error
-	DeleteUser(user User) error
-	GetUser(user string) (*User, error)
+	// AddCheck stores the check and adds a history row for its latest run
+	AddCheck(ctx context.Context, check Check, output CheckOutput) error
+	DeleteCheck(ctx context.Context, check Check, field string) error
+	GetCheck(ctx context.Context, searchField, searchType string) (Check, error)
+	GetAllChecks(ctx context.Context) ([]Check, error)
+	// GetCheckHistory returns up to amount history rows of a check, newest
+	// first. An amount of 0 or less returns all of them.
+	GetCheckHistory(ctx context.Context, check Check, amount int) ([]CheckHistory, error)
+	// GetHistoryBefore returns up to limit history rows of every check that
+	// finished before the given time, oldest first.
+	GetHistoryBefore(ctx context.Context, before time.Time, limit int) ([]CheckHistory, error)
+	// DeleteHistory permanently removes history rows by ID.
+	DeleteHistory(ctx context.Context, ids []uint) error
+	// GetRollups returns the rollups of a period whose start is within [from, to).
+	GetRollups(ctx context.Context, period string, from, to time.Time) ([]CheckHistoryRollup, error)
+	// SaveRollups inserts new rollups and updates the ones that have an ID.
+	SaveRollups(ctx context.Context, rollups []CheckHistoryRollup) error
+	// DeleteRollups permanently removes rollups by ID.
+	DeleteRollups(ctx context.Context, ids []uint) error
+	// ReplaceHistoryWithRollups saves the rollups and permanently removes the
+	// history rows and older rollups they were made from, all at once or not
+	// at all, so a failure part way through cannot count a run twice.
+	ReplaceHistoryWithRollups(ctx context.Context, rollups []CheckHistoryRollup, historyIDs, rollupIDs []uint) error
+	AddUser(ctx context.Context, user User) error
+	DeleteUser(ctx context.Context, user User) error
+	GetUser(ctx context.Context, user string) (*User, error)
+	GetAllUsers(ctx context.Context) ([]User, error)
 	// Init performs one time setup of the database and returns an error if the
 	// configuration is invalid.
 	Init() error
+	// Close releases any connections held by the database.
+	Close() error
+}
+
+// MigrationStatus describes one schema migration and whether it has been applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time // When the migration ran, nil if it is still pending
+	Unknown     bool       // Applied to the database but not known to this version of gogios
+}
+
+// Migrator is implemented by databases with a versioned schema. Init only
+// opens the connection; the tables are created and upgraded by Migrate.
+type Migrator interface {
+	// Migrate applies every pending migration in order and returns the ones
+	// that were applied.
+	Migrate(ctx context.Context) ([]MigrationStatus, error)
+	// SchemaStatus lists every migration, applied or not, in version order.
+	SchemaStatus(ctx context.Context) ([]MigrationStatus, error)
 }
@@ -1,6 +1,9 @@
 package all
 
 import (
+	_ "github.com/bkasin/gogios/databases/bolt"
+	_ "github.com/bkasin/gogios/databases/memory"
 	_ "github.com/bkasin/gogios/databases/mysql"
+	_ "github.com/bkasin/gogios/databases/postgres"
 	_ "github.com/bkasin/gogios/databases/sqlite"
 )
@@ -0,0 +1,620 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases"
+	"github.com/bkasin/gogios/helpers"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt stores everything in a single file using the pure Go bbolt key/value
+// store, so it needs neither CGO nor a database server
+type Bolt struct {
+	DBFile      string           `toml:"db_file"`
+	LockTimeout helpers.Duration `toml:"lock_timeout"`
+
+	db *bolt.DB
+}
+
+// Buckets and what their keys are made of. IDs are big endian uint64s and
+// times are encoded by timeBytes, so keys sort by check and then by time.
+var (
+	checksBucket      = []byte("checks")          // check ID -> Check
+	titlesBucket      = []byte("check_titles")    // title -> check ID
+	historyBucket     = []byte("history")         // check ID, asof, history ID -> CheckHistory
+	historyTimeBucket = []byte("history_by_time") // asof, history ID -> history key
+	historyIDsBucket  = []byte("history_ids")     // history ID -> history key
+	rollupsBucket     = []byte("rollups")         // period, "/", start, check ID -> CheckHistoryRollup
+	rollupIDsBucket   = []byte("rollup_ids")      // rollup ID -> rollup key
+	usersBucket       = []byte("users")           // username -> User
+
+	allBuckets = [][]byte{checksBucket, titlesBucket, historyBucket, historyTimeBucket, historyIDsBucket, rollupsBucket, rollupIDsBucket, usersBucket}
+)
+
+var sampleConfig = `
+  ## Path to the database file. It is created if it does not exist
+  db_file = "/var/lib/gogios/gogios.bolt"
+
+  ## Only one process can have the file open at a time. How long to wait
+  ## for another one to let go of it
+  # lock_timeout = "5s"
+`
+
+var subConfig = `
+  ## Path to the database file. It is created if it does not exist
+  db_file = "%s"
+
+  ## Only one process can have the file open at a time. How long to wait
+  ## for another one to let go of it
+  # lock_timeout = "5s"
+`
+
+// SampleConfig returns the default config for Bolt
+func (b *Bolt) SampleConfig() string {
+	return sampleConfig
+}
+
+// SubConfig returns the default config ready for variable substitution
+func (b *Bolt) SubConfig() string {
+	return subConfig
+}
+
+// Description returns a brief explanation of the database
+func (b *Bolt) Description() string {
+	return "Output check data to an embedded key/value file that needs no CGO"
+}
+
+// Init opens the database file, creating it and its buckets if needed
+func (b *Bolt) Init() error {
+	if b.DBFile == "" {
+		return fmt.Errorf("db_file is required")
+	}
+
+	db, err := bolt.Open(b.DBFile, 0600, &bolt.Options{Timeout: b.LockTimeout.Duration})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	b.db = db
+	return nil
+}
+
+// Close closes the database file
+func (b *Bolt) Close() error {
+	if b.db == nil {
+		return nil
+	}
+
+	return b.db.Close()
+}
+
+// view and update run a read only or read write transaction, after making
+// sure the database is open and the context is not done
+func (b *Bolt) view(ctx context.Context, fn func(*bolt.Tx) error) error {
+	if err := b.ready(ctx); err != nil {
+		return err
+	}
+
+	return b.db.View(fn)
+}
+
+func (b *Bolt) update(ctx context.Context, fn func(*bolt.Tx) error) error {
+	if err := b.ready(ctx); err != nil {
+		return err
+	}
+
+	return b.db.Update(fn)
+}
+
+func (b *Bolt) ready(ctx context.Context) error {
+	if b.db == nil {
+		return fmt.Errorf("database has not been initialized")
+	}
+
+	return ctx.Err()
+}
+
+// AddCheck makes sure an entry exists for the check and then adds to its history
+func (b *Bolt) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		checks := tx.Bucket(checksBucket)
+		titles := tx.Bucket(titlesBucket)
+		now := time.Now()
+
+		if check.ID == 0 {
+			if titles.Get([]byte(check.Title)) != nil {
+				return fmt.Errorf("check %q already exists", check.Title)
+			}
+
+			id, err := checks.NextSequence()
+			if err != nil {
+				return err
+			}
+			check.ID = uint(id)
+			check.CreatedAt = now
+		} else {
+			var existing gogios.Check
+			if err := getJSON(checks, itob(uint64(check.ID)), &existing); err != nil {
+				return fmt.Errorf("check %q: %w", check.Title, err)
+			}
+			if existing.Title != check.Title {
+				if err := titles.Delete([]byte(existing.Title)); err != nil {
+					return err
+				}
+			}
+			check.CreatedAt = existing.CreatedAt
+		}
+		check.UpdatedAt = now
+
+		if err := putJSON(checks, itob(uint64(check.ID)), check); err != nil {
+			return err
+		}
+		if err := titles.Put([]byte(check.Title), itob(uint64(check.ID))); err != nil {
+			return err
+		}
+
+		history := tx.Bucket(historyBucket)
+		id, err := history.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data := gogios.CheckHistory{
+			CheckID:   &check.ID,
+			Asof:      &check.Asof,
+			Output:    output.Stdout,
+			Status:    &check.Status,
+			Duration:  check.Duration,
+			ExitCode:  check.ExitCode,
+			Stderr:    output.Stderr,
+			Truncated: output.Truncated,
+		}
+		data.ID = uint(id)
+		data.CreatedAt = now
+		data.UpdatedAt = now
+
+		key := historyKey(check.ID, check.Asof, data.ID)
+		if err := putJSON(history, key, data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(historyTimeBucket).Put(key[8:], key); err != nil {
+			return err
+		}
+
+		return tx.Bucket(historyIDsBucket).Put(itob(id), key)
+	})
+}
+
+// DeleteCheck removes a check based on the title or ID. Its history is kept
+func (b *Bolt) DeleteCheck(ctx context.Context, check gogios.Check, field string) error {
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		var id []byte
+		switch field {
+		case "title":
+			id = tx.Bucket(titlesBucket).Get([]byte(check.Title))
+		case "id":
+			if check.ID != 0 && tx.Bucket(checksBucket).Get(itob(uint64(check.ID))) != nil {
+				id = itob(uint64(check.ID))
+			}
+		default:
+			return gogios.ErrInvalidField
+		}
+		if id == nil {
+			return fmt.Errorf("check %q: %w", check.Title, gogios.ErrNotFound)
+		}
+
+		var existing gogios.Check
+		if err := getJSON(tx.Bucket(checksBucket), id, &existing); err != nil {
+			return fmt.Errorf("check %q: %w", check.Title, err)
+		}
+		if err := tx.Bucket(titlesBucket).Delete([]byte(existing.Title)); err != nil {
+			return err
+		}
+
+		return tx.Bucket(checksBucket).Delete(id)
+	})
+}
+
+// GetCheck returns a single check. Searches using field (title or id)
+func (b *Bolt) GetCheck(ctx context.Context, searchField, searchType string) (gogios.Check, error) {
+	var check gogios.Check
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		var id []byte
+		switch searchType {
+		case "title":
+			id = tx.Bucket(titlesBucket).Get([]byte(searchField))
+		case "id":
+			if n, err := strconv.ParseUint(searchField, 10, 64); err == nil {
+				id = itob(n)
+			}
+		default:
+			return gogios.ErrInvalidField
+		}
+		if id == nil {
+			return gogios.ErrNotFound
+		}
+
+		return getJSON(tx.Bucket(checksBucket), id, &check)
+	})
+	if err == gogios.ErrNotFound {
+		return gogios.Check{}, fmt.Errorf("check %s %q: %w", searchType, searchField, gogios.ErrNotFound)
+	}
+
+	return check, err
+}
+
+// GetAllChecks returns every check, ordered by ID
+func (b *Bolt) GetAllChecks(ctx context.Context) ([]gogios.Check, error) {
+	data := []gogios.Check{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket(checksBucket).ForEach(func(k, v []byte) error {
+			var check gogios.Check
+			if err := json.Unmarshal(v, &check); err != nil {
+				return err
+			}
+			data = append(data, check)
+			return nil
+		})
+	})
+
+	return data, err
+}
+
+// GetCheckHistory returns $amount of rows of history for a check, newest first
+func (b *Bolt) GetCheckHistory(ctx context.Context, check gogios.Check, amount int) ([]gogios.CheckHistory, error) {
+	data := []gogios.CheckHistory{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		prefix := itob(uint64(check.ID))
+		c := tx.Bucket(historyBucket).Cursor()
+
+		// Start from the last key of this check and walk backwards
+		k, v := c.Seek(itob(uint64(check.ID) + 1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			if amount > 0 && len(data) >= amount {
+				break
+			}
+
+			var row gogios.CheckHistory
+			if err := json.Unmarshal(v, &row); err != nil {
+				return err
+			}
+			data = append(data, row)
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+// GetHistoryBefore returns up to limit history rows older than before, oldest first
+func (b *Bolt) GetHistoryBefore(ctx context.Context, before time.Time, limit int) ([]gogios.CheckHistory, error) {
+	data := []gogios.CheckHistory{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		end := timeBytes(before)
+		c := tx.Bucket(historyTimeBucket).Cursor()
+
+		for k, key := c.First(); k != nil && bytes.Compare(k[:len(end)], end) < 0; k, key = c.Next() {
+			if limit > 0 && len(data) >= limit {
+				break
+			}
+
+			var row gogios.CheckHistory
+			if err := getJSON(history, key, &row); err != nil {
+				return err
+			}
+			data = append(data, row)
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+// DeleteHistory removes history rows by ID
+func (b *Bolt) DeleteHistory(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		return deleteHistory(tx, ids)
+	})
+}
+
+func deleteHistory(tx *bolt.Tx, ids []uint) error {
+	byID := tx.Bucket(historyIDsBucket)
+	for _, id := range ids {
+		key := byID.Get(itob(uint64(id)))
+		if key == nil {
+			continue
+		}
+		// key is only valid until the bucket changes
+		key = append([]byte{}, key...)
+
+		if err := tx.Bucket(historyBucket).Delete(key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(historyTimeBucket).Delete(key[8:]); err != nil {
+			return err
+		}
+		if err := byID.Delete(itob(uint64(id))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRollups returns the rollups of a period that start within [from, to)
+func (b *Bolt) GetRollups(ctx context.Context, period string, from, to time.Time) ([]gogios.CheckHistoryRollup, error) {
+	data := []gogios.CheckHistoryRollup{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		prefix := []byte(period + "/")
+		end := append(append([]byte{}, prefix...), timeBytes(to)...)
+		c := tx.Bucket(rollupsBucket).Cursor()
+
+		for k, v := c.Seek(append(append([]byte{}, prefix...), timeBytes(from)...)); k != nil; k, v = c.Next() {
+			if !bytes.HasPrefix(k, prefix) || bytes.Compare(k[:len(end)], end) >= 0 {
+				break
+			}
+
+			var rollup gogios.CheckHistoryRollup
+			if err := json.Unmarshal(v, &rollup); err != nil {
+				return err
+			}
+			data = append(data, rollup)
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+// SaveRollups inserts new rollups and updates existing ones in one transaction
+func (b *Bolt) SaveRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup) error {
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		return saveRollups(tx, rollups)
+	})
+}
+
+func saveRollups(tx *bolt.Tx, rollups []gogios.CheckHistoryRollup) error {
+	bucket := tx.Bucket(rollupsBucket)
+	byID := tx.Bucket(rollupIDsBucket)
+	now := time.Now()
+
+	for _, rollup := range rollups {
+		if rollup.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			rollup.ID = uint(id)
+			rollup.CreatedAt = now
+		} else if old := byID.Get(itob(uint64(rollup.ID))); old != nil {
+			if err := bucket.Delete(append([]byte{}, old...)); err != nil {
+				return err
+			}
+		} else if id := uint64(rollup.ID); id > bucket.Sequence() {
+			// A rollup copied from another database keeps its ID, so new
+			// ones have to start after it
+			if err := bucket.SetSequence(id); err != nil {
+				return err
+			}
+		}
+		rollup.UpdatedAt = now
+
+		key := rollupKey(rollup)
+		if err := putJSON(bucket, key, rollup); err != nil {
+			return err
+		}
+		if err := byID.Put(itob(uint64(rollup.ID)), key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRollups removes rollups by ID
+func (b *Bolt) DeleteRollups(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		return deleteRollups(tx, ids)
+	})
+}
+
+func deleteRollups(tx *bolt.Tx, ids []uint) error {
+	byID := tx.Bucket(rollupIDsBucket)
+	for _, id := range ids {
+		key := byID.Get(itob(uint64(id)))
+		if key == nil {
+			continue
+		}
+
+		if err := tx.Bucket(rollupsBucket).Delete(append([]byte{}, key...)); err != nil {
+			return err
+		}
+		if err := byID.Delete(itob(uint64(id))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceHistoryWithRollups saves the rollups and removes the history rows and
+// rollups they replace in one transaction
+func (b *Bolt) ReplaceHistoryWithRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup, historyIDs, rollupIDs []uint) error {
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		if err := saveRollups(tx, rollups); err != nil {
+			return err
+		}
+		if err := deleteHistory(tx, historyIDs); err != nil {
+			return err
+		}
+
+		return deleteRollups(tx, rollupIDs)
+	})
+}
+
+// AddUser inserts a new user into the database
+func (b *Bolt) AddUser(ctx context.Context, user gogios.User) error {
+	if user.Username == "" || user.Password == "" {
+		return gogios.ErrInvalidUser
+	}
+
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		if users.Get([]byte(user.Username)) != nil {
+			return fmt.Errorf("user %q already exists", user.Username)
+		}
+
+		id, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+		user.ID = uint(id)
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+
+		return putJSON(users, []byte(user.Username), user)
+	})
+}
+
+// DeleteUser removes the user, and with them their password
+func (b *Bolt) DeleteUser(ctx context.Context, user gogios.User) error {
+	return b.update(ctx, func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		if users.Get([]byte(user.Username)) == nil {
+			return fmt.Errorf("user %q: %w", user.Username, gogios.ErrNotFound)
+		}
+
+		return users.Delete([]byte(user.Username))
+	})
+}
+
+// GetUser looks up a user by username and returns their struct
+func (b *Bolt) GetUser(ctx context.Context, user string) (*gogios.User, error) {
+	data := gogios.User{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(usersBucket), []byte(user), &data)
+	})
+	if err == gogios.ErrNotFound {
+		return &data, fmt.Errorf("user %q: %w", user, gogios.ErrNotFound)
+	}
+
+	return &data, err
+}
+
+// GetAllUsers returns every user, ordered by username
+func (b *Bolt) GetAllUsers(ctx context.Context) ([]gogios.User, error) {
+	data := []gogios.User{}
+
+	err := b.view(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var user gogios.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			data = append(data, user)
+			return nil
+		})
+	})
+
+	return data, err
+}
+
+func getJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data := bucket.Get(key)
+	if data == nil {
+		return gogios.ErrNotFound
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, data)
+}
+
+// itob encodes an ID so that keys sort numerically
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// timeBytes encodes a time so that keys sort chronologically. The seconds have
+// their sign bit flipped so times before 1970, and the zero time, still sort
+// first.
+func timeBytes(t time.Time) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
+
+func historyKey(checkID uint, asof time.Time, id uint) []byte {
+	key := itob(uint64(checkID))
+	key = append(key, timeBytes(asof)...)
+	return append(key, itob(uint64(id))...)
+}
+
+func rollupKey(rollup gogios.CheckHistoryRollup) []byte {
+	key := []byte(rollup.Period + "/")
+	key = append(key, timeBytes(rollup.Start)...)
+	return append(key, itob(uint64(rollup.CheckID))...)
+}
+
+func init() {
+	databases.Add("bolt", func() gogios.Database {
+		return &Bolt{
+			LockTimeout: helpers.Duration{Duration: 5 * time.Second},
+		}
+	})
+}
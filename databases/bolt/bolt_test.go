@@ -0,0 +1,82 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/dbtest"
+)
+
+func open(t *testing.T) *Bolt {
+	t.Helper()
+
+	db := &Bolt{DBFile: filepath.Join(t.TempDir(), "gogios.bolt")}
+	if err := db.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) gogios.Database {
+		return open(t)
+	})
+}
+
+// TestForeignRollupIDs saves a rollup that keeps the ID it had in another
+// database, then checks that new rollups are numbered after it
+func TestForeignRollupIDs(t *testing.T) {
+	db := open(t)
+	ctx := context.Background()
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	foreign := gogios.CheckHistoryRollup{CheckID: 1, Period: gogios.RollupHour, Start: day, Up: 1}
+	foreign.ID = 5
+	if err := db.SaveRollups(ctx, []gogios.CheckHistoryRollup{foreign}); err != nil {
+		t.Fatalf("SaveRollups failed, got error: %s", err)
+	}
+
+	var fresh []gogios.CheckHistoryRollup
+	for i := 1; i <= 6; i++ {
+		fresh = append(fresh, gogios.CheckHistoryRollup{CheckID: 2, Period: gogios.RollupHour, Start: day.Add(time.Duration(i) * time.Hour), Up: i})
+	}
+	if err := db.SaveRollups(ctx, fresh); err != nil {
+		t.Fatalf("SaveRollups failed, got error: %s", err)
+	}
+
+	all, err := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRollups failed, got error: %s", err)
+	}
+	if len(all) != 7 {
+		t.Fatalf("Expected 7 rollups, got %d", len(all))
+	}
+	seen := map[uint]bool{}
+	for _, rollup := range all {
+		if seen[rollup.ID] {
+			t.Errorf("Rollup ID %d was used twice", rollup.ID)
+		}
+		seen[rollup.ID] = true
+		if rollup.CheckID == 2 && rollup.ID <= 5 {
+			t.Errorf("New rollup got ID %d, which is not after the foreign ID 5", rollup.ID)
+		}
+	}
+
+	// Deleting the foreign rollup by its ID must leave the new ones alone
+	if err := db.DeleteRollups(ctx, []uint{5}); err != nil {
+		t.Fatalf("DeleteRollups failed, got error: %s", err)
+	}
+	all, _ = db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if len(all) != 6 {
+		t.Errorf("Expected 6 rollups after deleting the foreign one, got %d", len(all))
+	}
+	for _, rollup := range all {
+		if rollup.CheckID != 2 {
+			t.Errorf("Expected only the new rollups to be left, got %+v", rollup)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package dbtest is a conformance suite for gogios.Database. Every backend
+// runs it from its own tests, so they all behave the same way to callers.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+// Opener returns a freshly initialized, empty database. Databases that
+// implement gogios.Migrator are migrated by Run, and all of them are closed
+// when the test ends.
+type Opener func(t *testing.T) gogios.Database
+
+// base is truncated to the second because not every backend stores fractions
+var base = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// Run runs every conformance test against databases made by open
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		f    func(*testing.T, gogios.Database)
+	}{
+		{"Checks", testChecks},
+		{"DeleteCheck", testDeleteCheck},
+		{"History", testHistory},
+		{"HistoryBefore", testHistoryBefore},
+		{"Rollups", testRollups},
+		{"ReplaceHistory", testReplaceHistory},
+		{"Users", testUsers},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := open(t)
+			t.Cleanup(func() {
+				if err := db.Close(); err != nil {
+					t.Errorf("Close failed, got error: %s", err)
+				}
+			})
+
+			if migrator, ok := db.(gogios.Migrator); ok {
+				if _, err := migrator.Migrate(context.Background()); err != nil {
+					t.Fatalf("Migrate failed, got error: %s", err)
+				}
+			}
+
+			test.f(t, db)
+		})
+	}
+}
+
+// addCheck stores a run of the check, creating it first if needed, the same
+// way runChecks does
+func addCheck(t *testing.T, db gogios.Database, title, status string, asof time.Time) gogios.Check {
+	t.Helper()
+	ctx := context.Background()
+
+	check := gogios.Check{Title: title, Command: "true", Status: status, Asof: asof, Duration: time.Second, ExitCode: gogios.StatusCode(status)}
+	prev, err := db.GetCheck(ctx, title, "title")
+	if err == nil {
+		check.ID = prev.ID
+		check.TotalCount = prev.TotalCount + 1
+	} else if !errors.Is(err, gogios.ErrNotFound) {
+		t.Fatalf("GetCheck(%q) failed, got error: %s", title, err)
+	}
+
+	output := gogios.CheckOutput{Stdout: status + " output", Stderr: status + " errors", Truncated: status != gogios.StatusSuccess}
+	if err := db.AddCheck(ctx, check, output); err != nil {
+		t.Fatalf("AddCheck(%q) failed, got error: %s", title, err)
+	}
+
+	stored, err := db.GetCheck(ctx, title, "title")
+	if err != nil {
+		t.Fatalf("GetCheck(%q) after AddCheck failed, got error: %s", title, err)
+	}
+
+	return stored
+}
+
+func testChecks(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+
+	_, err := db.GetCheck(ctx, "missing", "title")
+	if !errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing check, got: %v", err)
+	}
+	_, err = db.GetCheck(ctx, "missing", "name")
+	if !errors.Is(err, gogios.ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField for an unknown search type, got: %v", err)
+	}
+
+	first := addCheck(t, db, "ping", gogios.StatusSuccess, base)
+	if first.ID == 0 {
+		t.Fatal("Expected the stored check to have an ID")
+	}
+	if first.Status != gogios.StatusSuccess || !first.Asof.Equal(base) || first.Duration != time.Second {
+		t.Errorf("Stored check does not match what was added: %+v", first)
+	}
+
+	second := addCheck(t, db, "ping", gogios.StatusFailed, base.Add(time.Minute))
+	if second.ID != first.ID {
+		t.Errorf("Expected updating a check to keep ID %d, got %d", first.ID, second.ID)
+	}
+	if second.Status != gogios.StatusFailed || second.TotalCount != 1 || second.ExitCode != 2 {
+		t.Errorf("Expected the check to be updated, got: %+v", second)
+	}
+
+	third := addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(2*time.Minute))
+	if third.ExitCode != 0 {
+		t.Errorf("Expected the exit code to go back to 0, got %d", third.ExitCode)
+	}
+
+	expires := base.Add(30 * 24 * time.Hour)
+	third.Expires = &expires
+	if err := db.AddCheck(ctx, third, gogios.CheckOutput{}); err != nil {
+		t.Fatalf("AddCheck with an expiry failed, got error: %s", err)
+	}
+	withCert, err := db.GetCheck(ctx, "ping", "title")
+	if err != nil || withCert.Expires == nil || !withCert.Expires.Equal(expires) {
+		t.Errorf("Expected the expiry to be stored, got %v and %v", withCert.Expires, err)
+	}
+	if fourth := addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(3*time.Minute)); fourth.Expires != nil {
+		t.Errorf("Expected the expiry to be cleared, got %v", fourth.Expires)
+	}
+
+	withCert.Tags = "web,production"
+	if err := db.AddCheck(ctx, withCert, gogios.CheckOutput{}); err != nil {
+		t.Fatalf("AddCheck with tags failed, got error: %s", err)
+	}
+	if tagged, err := db.GetCheck(ctx, "ping", "title"); err != nil || tagged.Tags != "web,production" {
+		t.Errorf("Expected the tags to be stored, got %q and %v", tagged.Tags, err)
+	}
+	if untagged := addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(4*time.Minute)); untagged.Tags != "" {
+		t.Errorf("Expected the tags to be cleared, got %q", untagged.Tags)
+	}
+
+	byID, err := db.GetCheck(ctx, strconv.FormatUint(uint64(first.ID), 10), "id")
+	if err != nil || byID.Title != "ping" {
+		t.Errorf("Expected GetCheck by ID to find ping, got %+v and %v", byID, err)
+	}
+
+	addCheck(t, db, "http", gogios.StatusSuccess, base)
+	all, err := db.GetAllChecks(ctx)
+	if err != nil {
+		t.Fatalf("GetAllChecks failed, got error: %s", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 checks, got %d", len(all))
+	}
+}
+
+func testDeleteCheck(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+
+	ping := addCheck(t, db, "ping", gogios.StatusSuccess, base)
+	http := addCheck(t, db, "http", gogios.StatusSuccess, base)
+
+	if err := db.DeleteCheck(ctx, ping, "title"); err != nil {
+		t.Fatalf("DeleteCheck by title failed, got error: %s", err)
+	}
+	if err := db.DeleteCheck(ctx, ping, "title"); !errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a check twice, got: %v", err)
+	}
+	if err := db.DeleteCheck(ctx, http, "id"); err != nil {
+		t.Fatalf("DeleteCheck by ID failed, got error: %s", err)
+	}
+	if err := db.DeleteCheck(ctx, http, "name"); !errors.Is(err, gogios.ErrInvalidField) {
+		t.Errorf("Expected ErrInvalidField for an unknown field, got: %v", err)
+	}
+
+	if _, err := db.GetCheck(ctx, "ping", "title"); !errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected a deleted check to be gone, got: %v", err)
+	}
+	all, err := db.GetAllChecks(ctx)
+	if err != nil || len(all) != 0 {
+		t.Errorf("Expected no checks to be left, got %d and %v", len(all), err)
+	}
+}
+
+func testHistory(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+
+	var check gogios.Check
+	for i := 0; i < 5; i++ {
+		check = addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(time.Duration(i)*time.Minute))
+	}
+	http := addCheck(t, db, "http", gogios.StatusFailed, base)
+
+	failed, err := db.GetCheckHistory(ctx, http, 1)
+	if err != nil || len(failed) != 1 {
+		t.Fatalf("Expected 1 row of history for http, got %d and %v", len(failed), err)
+	}
+	if failed[0].ExitCode != 2 || failed[0].Stderr != "Failed errors" || !failed[0].Truncated {
+		t.Errorf("Failed history row does not match what was added: %+v", failed[0])
+	}
+
+	history, err := db.GetCheckHistory(ctx, check, 3)
+	if err != nil {
+		t.Fatalf("GetCheckHistory failed, got error: %s", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 rows of history, got %d", len(history))
+	}
+	if !history[0].Asof.Equal(base.Add(4 * time.Minute)) {
+		t.Errorf("Expected the newest row first, got one from %s", history[0].Asof)
+	}
+
+	all, err := db.GetCheckHistory(ctx, check, 0)
+	if err != nil || len(all) != 5 {
+		t.Errorf("Expected all 5 rows of history for an amount of 0, got %d and %v", len(all), err)
+	}
+	for _, row := range history {
+		if row.CheckID == nil || *row.CheckID != check.ID {
+			t.Errorf("Expected history of check %d only, got %+v", check.ID, row)
+		}
+		if row.Output != "Success output" || row.Status == nil || *row.Status != gogios.StatusSuccess || row.Duration != time.Second {
+			t.Errorf("History row does not match what was added: %+v", row)
+		}
+		if row.Stderr != "Success errors" || row.ExitCode != 0 || row.Truncated {
+			t.Errorf("History row does not keep stderr and the exit code apart: %+v", row)
+		}
+	}
+}
+
+func testHistoryBefore(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+
+	addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(2*time.Minute))
+	addCheck(t, db, "http", gogios.StatusSuccess, base)
+	addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(4*time.Minute))
+	addCheck(t, db, "http", gogios.StatusSuccess, base.Add(6*time.Minute))
+
+	history, err := db.GetHistoryBefore(ctx, base.Add(5*time.Minute), 2)
+	if err != nil {
+		t.Fatalf("GetHistoryBefore failed, got error: %s", err)
+	}
+	if len(history) != 2 || !history[0].Asof.Equal(base) || !history[1].Asof.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("Expected the two oldest rows, oldest first, got %+v", history)
+	}
+
+	if err := db.DeleteHistory(ctx, []uint{history[0].ID, history[1].ID}); err != nil {
+		t.Fatalf("DeleteHistory failed, got error: %s", err)
+	}
+
+	history, err = db.GetHistoryBefore(ctx, base.Add(5*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("GetHistoryBefore failed, got error: %s", err)
+	}
+	if len(history) != 1 || !history[0].Asof.Equal(base.Add(4*time.Minute)) {
+		t.Errorf("Expected only the row from 4 minutes in to be left, got %+v", history)
+	}
+}
+
+func testRollups(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+	day := base.Truncate(24 * time.Hour)
+
+	err := db.SaveRollups(ctx, []gogios.CheckHistoryRollup{
+		{CheckID: 1, Period: gogios.RollupHour, Start: day, Up: 10, Down: 2, AvgDuration: time.Second},
+		{CheckID: 2, Period: gogios.RollupHour, Start: day, Up: 12},
+		{CheckID: 1, Period: gogios.RollupHour, Start: day.Add(time.Hour), Up: 12},
+		{CheckID: 1, Period: gogios.RollupDay, Start: day, Up: 200, Down: 88},
+	})
+	if err != nil {
+		t.Fatalf("SaveRollups failed, got error: %s", err)
+	}
+
+	hourly, err := db.GetRollups(ctx, gogios.RollupHour, day, day.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetRollups failed, got error: %s", err)
+	}
+	if len(hourly) != 2 {
+		t.Fatalf("Expected 2 hourly rollups in the first hour, got %+v", hourly)
+	}
+	first := hourly[0]
+	if first.ID == 0 || first.CheckID != 1 || first.Up != 10 || first.Down != 2 || first.AvgDuration != time.Second || !first.Start.Equal(day) {
+		t.Errorf("Stored rollup does not match what was saved: %+v", first)
+	}
+
+	first.Up = 11
+	if err := db.SaveRollups(ctx, []gogios.CheckHistoryRollup{first}); err != nil {
+		t.Fatalf("SaveRollups update failed, got error: %s", err)
+	}
+
+	all, err := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRollups failed, got error: %s", err)
+	}
+	if len(all) != 3 || all[0].ID != first.ID || all[0].Up != 11 {
+		t.Errorf("Expected the update to replace the rollup, got %+v", all)
+	}
+
+	if err := db.DeleteRollups(ctx, []uint{first.ID}); err != nil {
+		t.Fatalf("DeleteRollups failed, got error: %s", err)
+	}
+	all, _ = db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if len(all) != 2 {
+		t.Errorf("Expected 2 hourly rollups after deleting one, got %+v", all)
+	}
+
+	daily, _ := db.GetRollups(ctx, gogios.RollupDay, time.Time{}, day.Add(24*time.Hour))
+	if len(daily) != 1 || daily[0].Up != 200 {
+		t.Errorf("Expected the daily rollup to be untouched, got %+v", daily)
+	}
+}
+
+func testReplaceHistory(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+	day := base.Truncate(24 * time.Hour)
+
+	addCheck(t, db, "ping", gogios.StatusSuccess, base)
+	addCheck(t, db, "ping", gogios.StatusFailed, base.Add(time.Minute))
+	addCheck(t, db, "ping", gogios.StatusSuccess, base.Add(time.Hour))
+	history, err := db.GetHistoryBefore(ctx, base.Add(time.Hour), 10)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 rows to replace, got %+v and %v", history, err)
+	}
+
+	if err := db.SaveRollups(ctx, []gogios.CheckHistoryRollup{{CheckID: *history[0].CheckID, Period: gogios.RollupHour, Start: day, Up: 5}}); err != nil {
+		t.Fatalf("SaveRollups failed, got error: %s", err)
+	}
+	old, _ := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if len(old) != 1 {
+		t.Fatalf("Expected 1 hourly rollup, got %+v", old)
+	}
+
+	rollups := []gogios.CheckHistoryRollup{
+		{CheckID: *history[0].CheckID, Period: gogios.RollupHour, Start: base, Up: 1, Down: 1},
+		{CheckID: *history[0].CheckID, Period: gogios.RollupDay, Start: day, Up: 5},
+	}
+	if err := db.ReplaceHistoryWithRollups(ctx, rollups, []uint{history[0].ID, history[1].ID}, []uint{old[0].ID}); err != nil {
+		t.Fatalf("ReplaceHistoryWithRollups failed, got error: %s", err)
+	}
+
+	left, _ := db.GetHistoryBefore(ctx, base.Add(24*time.Hour), 10)
+	if len(left) != 1 || !left[0].Asof.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected only the row from an hour in to be left, got %+v", left)
+	}
+	hourly, _ := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, day.Add(24*time.Hour))
+	if len(hourly) != 1 || hourly[0].Up != 1 || hourly[0].Down != 1 {
+		t.Errorf("Expected the old hourly rollup to be replaced by the new one, got %+v", hourly)
+	}
+	daily, _ := db.GetRollups(ctx, gogios.RollupDay, time.Time{}, day.Add(24*time.Hour))
+	if len(daily) != 1 || daily[0].Up != 5 {
+		t.Errorf("Expected the daily rollup to be saved, got %+v", daily)
+	}
+}
+
+func testUsers(t *testing.T, db gogios.Database) {
+	ctx := context.Background()
+
+	if err := db.AddUser(ctx, gogios.User{Username: "admin"}); !errors.Is(err, gogios.ErrInvalidUser) {
+		t.Errorf("Expected ErrInvalidUser without a password, got: %v", err)
+	}
+
+	user := gogios.User{Name: "Admin", Username: "admin", Password: "hash"}
+	if err := db.AddUser(ctx, user); err != nil {
+		t.Fatalf("AddUser failed, got error: %s", err)
+	}
+	if err := db.AddUser(ctx, user); err == nil {
+		t.Error("Expected adding the same username twice to fail")
+	}
+
+	stored, err := db.GetUser(ctx, "admin")
+	if err != nil {
+		t.Fatalf("GetUser failed, got error: %s", err)
+	}
+	if stored.Name != "Admin" || stored.Password != "hash" {
+		t.Errorf("Stored user does not match what was added: %+v", stored)
+	}
+	if err := db.AddUser(ctx, gogios.User{Username: "viewer", Password: "hash"}); err != nil {
+		t.Fatalf("AddUser failed, got error: %s", err)
+	}
+
+	all, err := db.GetAllUsers(ctx)
+	if err != nil || len(all) != 2 {
+		t.Errorf("Expected 2 users, got %d and %v", len(all), err)
+	}
+
+	if err := db.DeleteUser(ctx, user); err != nil {
+		t.Fatalf("DeleteUser failed, got error: %s", err)
+	}
+	if _, err := db.GetUser(ctx, "admin"); !errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected a deleted user to be gone, got: %v", err)
+	}
+	if err := db.DeleteUser(ctx, user); !errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a user twice, got: %v", err)
+	}
+	all, err = db.GetAllUsers(ctx)
+	if err != nil || len(all) != 1 || all[0].Username != "viewer" {
+		t.Errorf("Expected only viewer to be left, got %+v and %v", all, err)
+	}
+}
+
+func testCancelledContext(t *testing.T, db gogios.Database) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.AddCheck(ctx, gogios.Check{Title: "ping", Asof: base}, gogios.CheckOutput{}); err == nil {
+		t.Error("Expected AddCheck to fail with a cancelled context")
+	}
+	if _, err := db.GetAllChecks(ctx); err == nil {
+		t.Error("Expected GetAllChecks to fail with a cancelled context")
+	}
+	if _, err := db.GetUser(ctx, "admin"); err == nil || errors.Is(err, gogios.ErrNotFound) {
+		t.Errorf("Expected GetUser to fail with the context error, got: %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Package gormdb holds the GORM based storage shared by the SQL databases.
+// Each SQL database embeds a Store, opens its own connection in Init and then
+// hands it to Store.Open. The schema is managed by the migrate package.
+package gormdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/migrate"
+	"github.com/jinzhu/gorm"
+)
+
+// Store implements the storage methods of gogios.Database on top of GORM
+type Store struct {
+	db *gorm.DB
+}
+
+// Open takes ownership of an opened connection. The tables are created and
+// upgraded by Migrate.
+func (s *Store) Open(db *gorm.DB) error {
+	s.db = db
+
+	return nil
+}
+
+// Migrate applies any pending schema migrations
+func (s *Store) Migrate(ctx context.Context) ([]gogios.MigrationStatus, error) {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.Up(ctx, db)
+}
+
+// SchemaStatus lists the schema migrations and whether each has been applied
+func (s *Store) SchemaStatus(ctx context.Context) ([]gogios.MigrationStatus, error) {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.Status(ctx, db)
+}
+
+// DB returns the underlying connection, or an error if the context is done.
+// GORM v1 cannot cancel a statement that is already running, so the context
+// is checked before each one starts.
+func (s *Store) DB(ctx context.Context) (*gorm.DB, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database has not been initialized")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.db, nil
+}
+
+// Close releases the connection pool
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+// AddCheck makes sure an entry exists for the check and then adds to its history
+func (s *Store) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.NewRecord(check) {
+		err = tx.Create(&check).Error
+	} else {
+		// Updates skips zero values, so an exit code of 0, a missing expiry
+		// and no tags are set on their own
+		err = tx.Model(&check).Updates(&check).Error
+		if err == nil {
+			err = tx.Model(&check).UpdateColumns(map[string]interface{}{"exit_code": check.ExitCode, "expires": check.Expires, "tags": check.Tags}).Error
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	data := gogios.CheckHistory{
+		CheckID:   &check.ID,
+		Asof:      &check.Asof,
+		Output:    output.Stdout,
+		Status:    &check.Status,
+		Duration:  check.Duration,
+		ExitCode:  check.ExitCode,
+		Stderr:    output.Stderr,
+		Truncated: output.Truncated,
+	}
+	if err := tx.Create(&data).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteCheck will remove a row from the check table based on the title or ID
+func (s *Store) DeleteCheck(ctx context.Context, check gogios.Check, field string) error {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	var result *gorm.DB
+	switch field {
+	case "title":
+		result = db.Where("title = ?", check.Title).Delete(&gogios.Check{})
+	case "id":
+		if check.ID == 0 {
+			return fmt.Errorf("check %q: %w", check.Title, gogios.ErrNotFound)
+		}
+		result = db.Delete(&gogios.Check{Model: gorm.Model{ID: check.ID}})
+	default:
+		return gogios.ErrInvalidField
+	}
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("check %q: %w", check.Title, gogios.ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetCheck returns a single row. Searches using field (title or id) and returns
+// the last record that matches
+func (s *Store) GetCheck(ctx context.Context, searchField, searchType string) (gogios.Check, error) {
+	lastRow := gogios.Check{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return lastRow, err
+	}
+
+	switch searchType {
+	case "title":
+		err = db.Where("title = ?", searchField).Last(&lastRow).Error
+	case "id":
+		err = db.Where("id = ?", searchField).Last(&lastRow).Error
+	default:
+		return lastRow, gogios.ErrInvalidField
+	}
+
+	if gorm.IsRecordNotFoundError(err) {
+		return gogios.Check{}, fmt.Errorf("check %s %q: %w", searchType, searchField, gogios.ErrNotFound)
+	}
+
+	return lastRow, err
+}
+
+// GetAllChecks returns all the rows in the check table
+func (s *Store) GetAllChecks(ctx context.Context) ([]gogios.Check, error) {
+	data := []gogios.Check{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return data, err
+	}
+
+	err = db.Where("deleted_at IS NULL").Find(&data).Error
+
+	return data, err
+}
+
+// GetCheckHistory returns $amount of rows of history for a check, newest first
+func (s *Store) GetCheckHistory(ctx context.Context, check gogios.Check, amount int) ([]gogios.CheckHistory, error) {
+	data := []gogios.CheckHistory{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return data, err
+	}
+	if amount <= 0 {
+		// GORM leaves out the LIMIT clause for negative limits
+		amount = -1
+	}
+
+	err = db.Where("check_id = ?", check.ID).Order("asof DESC").Limit(amount).Find(&data).Error
+
+	return data, err
+}
+
+// GetHistoryBefore returns up to limit history rows older than before, oldest first
+func (s *Store) GetHistoryBefore(ctx context.Context, before time.Time, limit int) ([]gogios.CheckHistory, error) {
+	data := []gogios.CheckHistory{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return data, err
+	}
+
+	err = db.Where("asof < ?", before).Order("asof ASC").Limit(limit).Find(&data).Error
+
+	return data, err
+}
+
+// DeleteHistory permanently removes history rows by ID
+func (s *Store) DeleteHistory(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Unscoped().Where("id IN (?)", ids).Delete(&gogios.CheckHistory{}).Error
+}
+
+// GetRollups returns the rollups of a period that start within [from, to)
+func (s *Store) GetRollups(ctx context.Context, period string, from, to time.Time) ([]gogios.CheckHistoryRollup, error) {
+	data := []gogios.CheckHistoryRollup{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return data, err
+	}
+
+	err = db.Where("period = ? AND start >= ? AND start < ?", period, from, to).Order("start ASC").Find(&data).Error
+
+	return data, err
+}
+
+// SaveRollups inserts new rollups and updates existing ones in one transaction
+func (s *Store) SaveRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup) error {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for i := range rollups {
+		if err := tx.Save(&rollups[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteRollups permanently removes rollups by ID
+func (s *Store) DeleteRollups(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Unscoped().Where("id IN (?)", ids).Delete(&gogios.CheckHistoryRollup{}).Error
+}
+
+// ReplaceHistoryWithRollups saves the rollups and removes the history rows and
+// rollups they replace in one transaction
+func (s *Store) ReplaceHistoryWithRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup, historyIDs, rollupIDs []uint) error {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for i := range rollups {
+		if err := tx.Save(&rollups[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(historyIDs) > 0 {
+		if err := tx.Unscoped().Where("id IN (?)", historyIDs).Delete(&gogios.CheckHistory{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if len(rollupIDs) > 0 {
+		if err := tx.Unscoped().Where("id IN (?)", rollupIDs).Delete(&gogios.CheckHistoryRollup{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// AddUser inserts a new user into the database
+func (s *Store) AddUser(ctx context.Context, user gogios.User) error {
+	if user.Username == "" || user.Password == "" {
+		return gogios.ErrInvalidUser
+	}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&user).Error
+}
+
+// DeleteUser blanks the user's password and then sets their DeletedAt value
+func (s *Store) DeleteUser(ctx context.Context, user gogios.User) error {
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := db.Model(&gogios.User{}).Where("username = ?", user.Username).Update("password", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user %q: %w", user.Username, gogios.ErrNotFound)
+	}
+
+	return db.Where("username = ?", user.Username).Delete(&gogios.User{}).Error
+}
+
+// GetUser looks up a user by username and returns their struct
+func (s *Store) GetUser(ctx context.Context, user string) (*gogios.User, error) {
+	data := gogios.User{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return &data, err
+	}
+
+	err = db.Where("username = ?", user).First(&data).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &data, fmt.Errorf("user %q: %w", user, gogios.ErrNotFound)
+	}
+
+	return &data, err
+}
+
+// GetAllUsers returns every user that has not been deleted
+func (s *Store) GetAllUsers(ctx context.Context) ([]gogios.User, error) {
+	data := []gogios.User{}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return data, err
+	}
+
+	err = db.Order("id ASC").Find(&data).Error
+
+	return data, err
+}
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/jinzhu/gorm"
+)
+
+// BackfillStats counts what Backfill copied
+type BackfillStats struct {
+	Users   int
+	Checks  int
+	Skipped int // Checks that already existed in the target
+	History int
+	Rollups int
+}
+
+// Backfill copies users, checks, their history and their rollups from one
+// database into another, usually a newly added one. Checks and users that
+// already exist in the target are left alone.
+func Backfill(ctx context.Context, from, to gogios.Database) (BackfillStats, error) {
+	var stats BackfillStats
+
+	users, err := from.GetAllUsers(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("reading users: %w", err)
+	}
+	for _, user := range users {
+		_, err := to.GetUser(ctx, user.Username)
+		if err == nil {
+			continue
+		} else if !errors.Is(err, gogios.ErrNotFound) {
+			return stats, err
+		}
+
+		user.Model = gorm.Model{}
+		if err := to.AddUser(ctx, user); err != nil {
+			return stats, fmt.Errorf("adding user %s: %w", user.Username, err)
+		}
+		stats.Users++
+	}
+
+	rollups, err := rollupsByCheck(ctx, from)
+	if err != nil {
+		return stats, err
+	}
+
+	checks, err := from.GetAllChecks(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("reading checks: %w", err)
+	}
+	for _, check := range checks {
+		_, err := to.GetCheck(ctx, check.Title, "title")
+		if err == nil {
+			stats.Skipped++
+			continue
+		} else if !errors.Is(err, gogios.ErrNotFound) {
+			return stats, err
+		}
+
+		copied, err := backfillCheck(ctx, from, to, check)
+		stats.History += copied
+		if err != nil {
+			return stats, fmt.Errorf("copying check %s: %w", check.Title, err)
+		}
+		stats.Checks++
+
+		stored, err := to.GetCheck(ctx, check.Title, "title")
+		if err != nil {
+			return stats, err
+		}
+
+		var fresh []gogios.CheckHistoryRollup
+		for _, rollup := range rollups[check.ID] {
+			rollup.Model = gorm.Model{}
+			rollup.CheckID = stored.ID
+			fresh = append(fresh, rollup)
+		}
+		if len(fresh) > 0 {
+			if err := to.SaveRollups(ctx, fresh); err != nil {
+				return stats, fmt.Errorf("copying rollups of %s: %w", check.Title, err)
+			}
+			stats.Rollups += len(fresh)
+		}
+	}
+
+	return stats, nil
+}
+
+// backfillCheck replays a check's history oldest first, which leaves the
+// target with the same history and the same latest state. A check whose
+// history has all been pruned is added with a single empty run.
+func backfillCheck(ctx context.Context, from, to gogios.Database, check gogios.Check) (int, error) {
+	history, err := from.GetCheckHistory(ctx, check, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(history) == 0 {
+		check.Model = gorm.Model{}
+		return 0, to.AddCheck(ctx, check, gogios.CheckOutput{})
+	}
+
+	var id uint
+	for i := len(history) - 1; i >= 0; i-- {
+		row := history[i]
+
+		run := check
+		run.Model = gorm.Model{ID: id}
+		if row.Asof != nil {
+			run.Asof = *row.Asof
+		}
+		if row.Status != nil {
+			run.Status = *row.Status
+		}
+		run.Duration = row.Duration
+		run.ExitCode = row.ExitCode
+
+		output := gogios.CheckOutput{Stdout: row.Output, Stderr: row.Stderr, Truncated: row.Truncated}
+		if err := to.AddCheck(ctx, run, output); err != nil {
+			return len(history) - 1 - i, err
+		}
+
+		if id == 0 {
+			stored, err := to.GetCheck(ctx, check.Title, "title")
+			if err != nil {
+				return len(history) - i, err
+			}
+			id = stored.ID
+		}
+	}
+
+	return len(history), nil
+}
+
+// rollupsByCheck reads every rollup, grouped by the ID of their check
+func rollupsByCheck(ctx context.Context, db gogios.Database) (map[uint][]gogios.CheckHistoryRollup, error) {
+	result := map[uint][]gogios.CheckHistoryRollup{}
+	end := time.Now().Add(24 * time.Hour)
+
+	for _, period := range []string{gogios.RollupHour, gogios.RollupDay} {
+		rollups, err := db.GetRollups(ctx, period, time.Time{}, end)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s rollups: %w", period, err)
+		}
+
+		for _, rollup := range rollups {
+			result[rollup.CheckID] = append(result[rollup.CheckID], rollup)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,500 @@
+// Package manager spreads check data over every configured database. Writes
+// go to all of them, reads come from the first one that is healthy and up to
+// date, and writes for a database that is down are queued until it is back.
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/helpers/metrics"
+	"github.com/bkasin/gogios/helpers/models"
+	"github.com/google/logger"
+)
+
+// Manager satisfies gogios.Database so it can be used anywhere a single
+// database was. Init and Close are left to the individual databases.
+type Manager struct {
+	members   []*member
+	queueSize int
+}
+
+// member is the state kept for each database
+type member struct {
+	db *models.ActiveDatabase
+
+	mu      sync.Mutex
+	healthy bool
+	since   time.Time
+	lastErr error
+	queue   []write
+	queued  uint64 // Writes ever queued, which numbers the next one
+	dropped int
+}
+
+// write is a change that can be replayed against a database later
+type write struct {
+	name string
+	f    func(context.Context, *models.ActiveDatabase) error
+	seq  uint64 // Set when queued, so a write can be found after the queue moves
+}
+
+// Health is a snapshot of the state of one database
+type Health struct {
+	Name    string
+	Healthy bool
+	Since   time.Time
+	Error   string `json:",omitempty"`
+	Queued  int
+	Dropped int // Writes lost because the queue was full
+}
+
+// New returns a manager for the databases, which are expected to be
+// initialized already. The first database is the primary. At most queueSize
+// writes are kept for a database that is down; older ones are dropped first.
+func New(databases []*models.ActiveDatabase, queueSize int) *Manager {
+	m := &Manager{queueSize: queueSize}
+	for _, db := range databases {
+		m.members = append(m.members, &member{db: db, healthy: true, since: time.Now()})
+	}
+
+	return m
+}
+
+// Health returns the state of every database, primary first
+func (m *Manager) Health() []Health {
+	var result []Health
+	for _, mem := range m.members {
+		mem.mu.Lock()
+		h := Health{Name: mem.db.LogName(), Healthy: mem.healthy, Since: mem.since, Queued: len(mem.queue), Dropped: mem.dropped}
+		if mem.lastErr != nil {
+			h.Error = mem.lastErr.Error()
+		}
+		mem.mu.Unlock()
+
+		result = append(result, h)
+	}
+
+	return result
+}
+
+// Run checks the health of every database once per interval until the
+// context is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckHealth(ctx)
+		}
+	}
+}
+
+// CheckHealth probes every database, and replays the queued writes of the
+// ones that can be reached
+func (m *Manager) CheckHealth(ctx context.Context) {
+	for _, mem := range m.members {
+		if err := probe(ctx, mem.db); err != nil {
+			if ctx.Err() == nil {
+				m.markFailed(mem, err)
+			}
+			continue
+		}
+
+		if m.replay(ctx, mem) {
+			m.markHealthy(mem)
+		}
+	}
+}
+
+// probe makes the cheapest read possible. Not finding anything is fine, it
+// only matters that the database answered
+func probe(ctx context.Context, db *models.ActiveDatabase) error {
+	_, err := db.Database.GetCheck(ctx, "0", "id")
+	if errors.Is(err, gogios.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// replay applies a database's queued writes in order. It returns false if the
+// database failed again before the queue was empty
+func (m *Manager) replay(ctx context.Context, mem *member) bool {
+	for {
+		mem.mu.Lock()
+		if len(mem.queue) == 0 {
+			mem.mu.Unlock()
+			return true
+		}
+		w := mem.queue[0]
+		mem.mu.Unlock()
+
+		err := w.f(ctx, mem.db)
+		if err != nil && ctx.Err() != nil {
+			return false
+		}
+		if err != nil && probe(ctx, mem.db) != nil {
+			m.markFailed(mem, err)
+			return false
+		}
+		if err != nil {
+			// The database is up, so the write itself is what failed.
+			// Retrying it would only block the rest of the queue
+			logger.Errorf("Dropping queued %s for database %s, error:\n%s", w.name, mem.db.LogName(), err.Error())
+		}
+
+		// The queue is unlocked while the write runs, so a full queue may have
+		// dropped it in the meantime
+		mem.mu.Lock()
+		if len(mem.queue) > 0 && mem.queue[0].seq == w.seq {
+			mem.queue = mem.queue[1:]
+		}
+		mem.mu.Unlock()
+	}
+}
+
+func (m *Manager) markFailed(mem *member, err error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	mem.lastErr = err
+	if mem.healthy {
+		mem.healthy = false
+		mem.since = time.Now()
+		logger.Errorf("Database %s is unhealthy, error:\n%s", mem.db.LogName(), err.Error())
+	}
+}
+
+func (m *Manager) markHealthy(mem *member) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	mem.lastErr = nil
+	if !mem.healthy {
+		mem.healthy = true
+		mem.since = time.Now()
+		logger.Infof("Database %s is healthy again", mem.db.LogName())
+		if mem.dropped > 0 {
+			logger.Warningf("Database %s missed %d writes while it was down. Run `gogios db backfill` to copy them from the primary", mem.db.LogName(), mem.dropped)
+		}
+	}
+}
+
+// failure reports whether err means the database itself is in trouble, as
+// opposed to the request being wrong or the caller giving up
+func failure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	return !errors.Is(err, gogios.ErrNotFound) && !errors.Is(err, gogios.ErrInvalidField) && !errors.Is(err, gogios.ErrInvalidUser)
+}
+
+// readers returns the databases that can serve reads: healthy ones with
+// nothing queued, primary first. If there are none every database is tried
+func (m *Manager) readers() []*member {
+	var result []*member
+	for _, mem := range m.members {
+		mem.mu.Lock()
+		if mem.healthy && len(mem.queue) == 0 {
+			result = append(result, mem)
+		}
+		mem.mu.Unlock()
+	}
+
+	if len(result) == 0 {
+		return m.members
+	}
+
+	return result
+}
+
+// read runs f against each reader in turn until one answers
+func (m *Manager) read(ctx context.Context, f func(gogios.Database) error) error {
+	if len(m.members) == 0 {
+		return fmt.Errorf("no databases are configured")
+	}
+
+	var err error
+	for _, mem := range m.readers() {
+		start := time.Now()
+		err = f(mem.db.Database)
+		metrics.ObserveDatabase(mem.db.LogName(), "read", time.Since(start))
+		if !failure(ctx, err) {
+			return err
+		}
+		m.markFailed(mem, err)
+	}
+
+	return err
+}
+
+// write applies a change to every database. Databases that are down or
+// behind get it queued instead. An error is returned if a database rejected
+// the change, or if none of them could take it right away.
+func (m *Manager) write(ctx context.Context, w write) error {
+	if len(m.members) == 0 {
+		return fmt.Errorf("no databases are configured")
+	}
+
+	var errs []error
+	stored := false
+	for _, mem := range m.members {
+		if m.enqueueIfBehind(mem, w) {
+			continue
+		}
+
+		start := time.Now()
+		err := w.f(ctx, mem.db)
+		metrics.ObserveDatabase(mem.db.LogName(), "write", time.Since(start))
+		if err == nil {
+			stored = true
+			continue
+		}
+
+		if failure(ctx, err) {
+			m.markFailed(mem, err)
+			m.enqueue(mem, w)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("database %s: %w", mem.db.LogName(), err))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if !stored {
+		return fmt.Errorf("no database could take the %s, it has been queued", w.name)
+	}
+
+	return nil
+}
+
+// enqueueIfBehind queues the write if the database is down or still has
+// older writes waiting, so writes are always applied in order
+func (m *Manager) enqueueIfBehind(mem *member, w write) bool {
+	mem.mu.Lock()
+	behind := !mem.healthy || len(mem.queue) > 0
+	mem.mu.Unlock()
+
+	if behind {
+		m.enqueue(mem, w)
+	}
+
+	return behind
+}
+
+func (m *Manager) enqueue(mem *member, w write) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	if m.queueSize <= 0 {
+		mem.dropped++
+		return
+	}
+	if len(mem.queue) >= m.queueSize {
+		if mem.dropped == 0 {
+			logger.Warningf("Write queue for database %s is full, dropping the oldest writes", mem.db.LogName())
+		}
+		mem.queue = mem.queue[1:]
+		mem.dropped++
+	}
+
+	w.seq = mem.queued
+	mem.queued++
+	mem.queue = append(mem.queue, w)
+}
+
+// SampleConfig is empty, the manager is not configured like a plugin
+func (m *Manager) SampleConfig() string {
+	return ""
+}
+
+// SubConfig is empty, the manager is not configured like a plugin
+func (m *Manager) SubConfig() string {
+	return ""
+}
+
+// Description returns a brief explanation of the manager
+func (m *Manager) Description() string {
+	return "Spread check data over every configured database"
+}
+
+// Init does nothing, each database is initialized on its own
+func (m *Manager) Init() error {
+	return nil
+}
+
+// Close does nothing, each database is closed on its own
+func (m *Manager) Close() error {
+	return nil
+}
+
+// AddCheck writes the check to every database
+func (m *Manager) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	return m.write(ctx, write{name: "result of " + check.Title, f: func(ctx context.Context, db *models.ActiveDatabase) error {
+		// IDs differ between databases, so each one looks the check up itself.
+		// The write can run for several databases at once, so it works on a
+		// copy
+		c := check
+		c.ID = 0
+		prev, err := db.Database.GetCheck(ctx, c.Title, "title")
+		if err == nil {
+			c.ID = prev.ID
+		} else if !errors.Is(err, gogios.ErrNotFound) {
+			return err
+		}
+
+		return db.AddCheck(ctx, c, output)
+	}})
+}
+
+// DeleteCheck removes the check from every database by title, since IDs
+// differ between databases
+func (m *Manager) DeleteCheck(ctx context.Context, check gogios.Check, field string) error {
+	switch field {
+	case "title":
+	case "id":
+		stored, err := m.GetCheck(ctx, strconv.FormatUint(uint64(check.ID), 10), "id")
+		if err != nil {
+			return err
+		}
+		check.Title = stored.Title
+	default:
+		return gogios.ErrInvalidField
+	}
+
+	return m.write(ctx, write{name: "removal of " + check.Title, f: func(ctx context.Context, db *models.ActiveDatabase) error {
+		return db.DeleteCheck(ctx, check, "title")
+	}})
+}
+
+// GetCheck reads a check from the first healthy database
+func (m *Manager) GetCheck(ctx context.Context, searchField, searchType string) (gogios.Check, error) {
+	var check gogios.Check
+	err := m.read(ctx, func(db gogios.Database) (err error) {
+		check, err = db.GetCheck(ctx, searchField, searchType)
+		return err
+	})
+
+	return check, err
+}
+
+// GetAllChecks reads every check from the first healthy database
+func (m *Manager) GetAllChecks(ctx context.Context) ([]gogios.Check, error) {
+	var checks []gogios.Check
+	err := m.read(ctx, func(db gogios.Database) (err error) {
+		checks, err = db.GetAllChecks(ctx)
+		return err
+	})
+
+	return checks, err
+}
+
+// GetCheckHistory reads a check's history from the first healthy database.
+// The check is looked up by title, since IDs differ between databases
+func (m *Manager) GetCheckHistory(ctx context.Context, check gogios.Check, amount int) ([]gogios.CheckHistory, error) {
+	var history []gogios.CheckHistory
+	err := m.read(ctx, func(db gogios.Database) error {
+		local, err := db.GetCheck(ctx, check.Title, "title")
+		if err != nil {
+			return err
+		}
+
+		history, err = db.GetCheckHistory(ctx, local, amount)
+		return err
+	})
+
+	return history, err
+}
+
+// GetHistoryBefore reads from the primary only, since the IDs it returns are
+// only meaningful there
+func (m *Manager) GetHistoryBefore(ctx context.Context, before time.Time, limit int) ([]gogios.CheckHistory, error) {
+	return m.primary().GetHistoryBefore(ctx, before, limit)
+}
+
+// DeleteHistory deletes from the primary only. Each database prunes its own
+// history
+func (m *Manager) DeleteHistory(ctx context.Context, ids []uint) error {
+	return m.primary().DeleteHistory(ctx, ids)
+}
+
+// GetRollups reads from the primary only
+func (m *Manager) GetRollups(ctx context.Context, period string, from, to time.Time) ([]gogios.CheckHistoryRollup, error) {
+	return m.primary().GetRollups(ctx, period, from, to)
+}
+
+// SaveRollups saves to the primary only
+func (m *Manager) SaveRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup) error {
+	return m.primary().SaveRollups(ctx, rollups)
+}
+
+// DeleteRollups deletes from the primary only
+func (m *Manager) DeleteRollups(ctx context.Context, ids []uint) error {
+	return m.primary().DeleteRollups(ctx, ids)
+}
+
+// ReplaceHistoryWithRollups replaces history in the primary only
+func (m *Manager) ReplaceHistoryWithRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup, historyIDs, rollupIDs []uint) error {
+	return m.primary().ReplaceHistoryWithRollups(ctx, rollups, historyIDs, rollupIDs)
+}
+
+// AddUser adds the user to every database
+func (m *Manager) AddUser(ctx context.Context, user gogios.User) error {
+	if user.Username == "" || user.Password == "" {
+		return gogios.ErrInvalidUser
+	}
+
+	return m.write(ctx, write{name: "new user " + user.Username, f: func(ctx context.Context, db *models.ActiveDatabase) error {
+		return db.AddUser(ctx, user)
+	}})
+}
+
+// DeleteUser removes the user from every database
+func (m *Manager) DeleteUser(ctx context.Context, user gogios.User) error {
+	return m.write(ctx, write{name: "removal of user " + user.Username, f: func(ctx context.Context, db *models.ActiveDatabase) error {
+		return db.DeleteUser(ctx, user)
+	}})
+}
+
+// GetUser reads a user from the first healthy database
+func (m *Manager) GetUser(ctx context.Context, username string) (*gogios.User, error) {
+	var user *gogios.User
+	err := m.read(ctx, func(db gogios.Database) (err error) {
+		user, err = db.GetUser(ctx, username)
+		return err
+	})
+
+	return user, err
+}
+
+// GetAllUsers reads every user from the first healthy database
+func (m *Manager) GetAllUsers(ctx context.Context) ([]gogios.User, error) {
+	var users []gogios.User
+	err := m.read(ctx, func(db gogios.Database) (err error) {
+		users, err = db.GetAllUsers(ctx)
+		return err
+	})
+
+	return users, err
+}
+
+// primary returns the first configured database
+func (m *Manager) primary() gogios.Database {
+	return m.members[0].db.Database
+}
@@ -0,0 +1,291 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/memory"
+	"github.com/bkasin/gogios/helpers/models"
+)
+
+var errDown = errors.New("connection refused")
+
+// flaky is a memory database that can be switched off. If gate is set,
+// AddCheck signals started and waits for gate to be closed
+type flaky struct {
+	*memory.Memory
+	down    atomic.Bool
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func (f *flaky) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	if f.down.Load() {
+		return errDown
+	}
+	if f.gate != nil {
+		select {
+		case f.started <- struct{}{}:
+		default:
+		}
+		<-f.gate
+	}
+	return f.Memory.AddCheck(ctx, check, output)
+}
+
+func (f *flaky) GetCheck(ctx context.Context, searchField, searchType string) (gogios.Check, error) {
+	if f.down.Load() {
+		return gogios.Check{}, errDown
+	}
+	return f.Memory.GetCheck(ctx, searchField, searchType)
+}
+
+func (f *flaky) GetAllChecks(ctx context.Context) ([]gogios.Check, error) {
+	if f.down.Load() {
+		return nil, errDown
+	}
+	return f.Memory.GetAllChecks(ctx)
+}
+
+func newFlaky(t *testing.T) *flaky {
+	db := &flaky{Memory: &memory.Memory{}}
+	if err := db.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+
+	return db
+}
+
+func active(name string, db gogios.Database) *models.ActiveDatabase {
+	return models.NewActiveDatabase(db, &models.DatabaseConfig{Name: name})
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newFlaky(t), newFlaky(t)
+	m := New([]*models.ActiveDatabase{active("primary", primary), active("secondary", secondary)}, 10)
+
+	check := gogios.Check{Title: "ping", Status: gogios.StatusSuccess, Asof: time.Now()}
+	if err := m.AddCheck(ctx, check, gogios.CheckOutput{Stdout: "ok"}); err != nil {
+		t.Fatalf("AddCheck failed, got error: %s", err)
+	}
+
+	primary.down.Store(true)
+	checks, err := m.GetAllChecks(ctx)
+	if err != nil || len(checks) != 1 {
+		t.Fatalf("Expected the read to fail over to the secondary, got %v and %v", checks, err)
+	}
+	if health := m.Health(); health[0].Healthy || !health[1].Healthy {
+		t.Errorf("Expected only the primary to be unhealthy, got %+v", health)
+	}
+
+	// Writes for the primary are queued while it is down
+	check.Status = gogios.StatusFailed
+	if err := m.AddCheck(ctx, check, gogios.CheckOutput{Stdout: "down"}); err != nil {
+		t.Fatalf("AddCheck failed, got error: %s", err)
+	}
+	if queued := m.Health()[0].Queued; queued != 1 {
+		t.Errorf("Expected 1 queued write, got %d", queued)
+	}
+
+	primary.down.Store(false)
+	m.CheckHealth(ctx)
+	if health := m.Health()[0]; !health.Healthy || health.Queued != 0 {
+		t.Errorf("Expected the primary to be healthy with nothing queued, got %+v", health)
+	}
+
+	stored, err := primary.GetCheck(ctx, "ping", "title")
+	if err != nil || stored.Status != gogios.StatusFailed {
+		t.Errorf("Expected the queued write to be replayed, got %+v and %v", stored, err)
+	}
+}
+
+// TestReplayDuringWrite replays a queued write while the same write runs live
+// on another database. Run with -race to catch the two sharing state
+func TestReplayDuringWrite(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newFlaky(t), newFlaky(t)
+	m := New([]*models.ActiveDatabase{active("primary", primary), active("secondary", secondary)}, 1000)
+
+	// Give the check different IDs in each database
+	secondary.AddCheck(ctx, gogios.Check{Title: "other", Asof: time.Now()}, gogios.CheckOutput{})
+
+	primary.down.Store(true)
+	m.AddCheck(ctx, gogios.Check{Title: "ping", Asof: time.Now()}, gogios.CheckOutput{})
+	primary.down.Store(false)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			m.CheckHealth(ctx)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			m.AddCheck(ctx, gogios.Check{Title: "ping", Asof: time.Now()}, gogios.CheckOutput{})
+		}
+	}()
+	wg.Wait()
+	m.CheckHealth(ctx)
+
+	for name, db := range map[string]*flaky{"primary": primary, "secondary": secondary} {
+		checks, err := db.GetAllChecks(ctx)
+		if err != nil {
+			t.Fatalf("GetAllChecks on the %s failed, got error: %s", name, err)
+		}
+		titles := 0
+		for _, check := range checks {
+			if check.Title == "ping" {
+				titles++
+			}
+		}
+		if titles != 1 {
+			t.Errorf("Expected one ping check in the %s, got %+v", name, checks)
+		}
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newFlaky(t), newFlaky(t)
+	m := New([]*models.ActiveDatabase{active("primary", primary), active("secondary", secondary)}, 2)
+
+	primary.down.Store(true)
+	for i := 0; i < 5; i++ {
+		m.AddCheck(ctx, gogios.Check{Title: "ping", Asof: time.Now()}, gogios.CheckOutput{})
+	}
+
+	if health := m.Health()[0]; health.Queued != 2 || health.Dropped != 3 {
+		t.Errorf("Expected 2 queued and 3 dropped writes, got %+v", health)
+	}
+}
+
+// TestQueueFullDuringReplay fills the queue while the write at its head is
+// being replayed, which drops that write. The replay must not then remove the
+// next write as well
+func TestQueueFullDuringReplay(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newFlaky(t), newFlaky(t)
+	m := New([]*models.ActiveDatabase{active("primary", primary), active("secondary", secondary)}, 2)
+
+	primary.down.Store(true)
+	for _, title := range []string{"first", "second"} {
+		m.AddCheck(ctx, gogios.Check{Title: title, Asof: time.Now()}, gogios.CheckOutput{})
+	}
+	primary.gate, primary.started = make(chan struct{}), make(chan struct{}, 1)
+	primary.down.Store(false)
+
+	done := make(chan struct{})
+	go func() {
+		m.CheckHealth(ctx)
+		close(done)
+	}()
+	<-primary.started
+
+	m.AddCheck(ctx, gogios.Check{Title: "third", Asof: time.Now()}, gogios.CheckOutput{})
+	close(primary.gate)
+	<-done
+
+	if health := m.Health()[0]; !health.Healthy || health.Queued != 0 {
+		t.Errorf("Expected the primary to be healthy with nothing queued, got %+v", health)
+	}
+	for _, title := range []string{"second", "third"} {
+		if _, err := primary.GetCheck(ctx, title, "title"); err != nil {
+			t.Errorf("Expected the queued %s write to be replayed, got error: %s", title, err)
+		}
+	}
+}
+
+// hung is a memory database whose user writes never return until release is
+// closed, whatever their context says
+type hung struct {
+	*memory.Memory
+	release chan struct{}
+}
+
+func (h *hung) AddUser(ctx context.Context, user gogios.User) error {
+	<-h.release
+	return nil
+}
+
+func (h *hung) DeleteUser(ctx context.Context, user gogios.User) error {
+	<-h.release
+	return nil
+}
+
+func TestUserTimeout(t *testing.T) {
+	ctx := context.Background()
+	db := &hung{Memory: &memory.Memory{}, release: make(chan struct{})}
+	t.Cleanup(func() { close(db.release) })
+	m := New([]*models.ActiveDatabase{models.NewActiveDatabase(db, &models.DatabaseConfig{Name: "hung", Timeout: 50 * time.Millisecond})}, 10)
+
+	user := gogios.User{Username: "admin", Password: "hash"}
+	for name, f := range map[string]func(context.Context, gogios.User) error{"AddUser": m.AddUser, "DeleteUser": m.DeleteUser} {
+		result := make(chan error, 1)
+		go func() { result <- f(ctx, user) }()
+		select {
+		case err := <-result:
+			if err == nil {
+				t.Errorf("%s on a hung database should have failed", name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s did not give up after the database timeout", name)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	from, to := newFlaky(t), newFlaky(t)
+
+	base := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, status := range []string{gogios.StatusSuccess, gogios.StatusFailed, gogios.StatusSuccess} {
+		check := gogios.Check{Title: "ping", Status: status, Asof: base.Add(time.Duration(i) * time.Minute)}
+		if prev, err := from.GetCheck(ctx, "ping", "title"); err == nil {
+			check.ID = prev.ID
+		}
+		if err := from.AddCheck(ctx, check, gogios.CheckOutput{Stdout: status}); err != nil {
+			t.Fatalf("AddCheck failed, got error: %s", err)
+		}
+	}
+	ping, _ := from.GetCheck(ctx, "ping", "title")
+	from.SaveRollups(ctx, []gogios.CheckHistoryRollup{{CheckID: ping.ID, Period: gogios.RollupDay, Start: base.Add(-24 * time.Hour), Up: 100}})
+	from.AddUser(ctx, gogios.User{Username: "admin", Password: "hash"})
+
+	// Something that is already in the target is left alone
+	to.AddCheck(ctx, gogios.Check{Title: "http", Asof: base}, gogios.CheckOutput{})
+	from.AddCheck(ctx, gogios.Check{Title: "http", Asof: base}, gogios.CheckOutput{})
+
+	stats, err := Backfill(ctx, from, to)
+	if err != nil {
+		t.Fatalf("Backfill failed, got error: %s", err)
+	}
+	want := BackfillStats{Users: 1, Checks: 1, Skipped: 1, History: 3, Rollups: 1}
+	if stats != want {
+		t.Errorf("Expected %+v, got %+v", want, stats)
+	}
+
+	copied, err := to.GetCheck(ctx, "ping", "title")
+	if err != nil || copied.Status != gogios.StatusSuccess || !copied.Asof.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Expected the latest state to be copied, got %+v and %v", copied, err)
+	}
+	history, _ := to.GetCheckHistory(ctx, copied, 0)
+	if len(history) != 3 || *history[1].Status != gogios.StatusFailed {
+		t.Errorf("Expected the history to be copied in order, got %+v", history)
+	}
+	rollups, _ := to.GetRollups(ctx, gogios.RollupDay, time.Time{}, base)
+	if len(rollups) != 1 || rollups[0].CheckID != copied.ID {
+		t.Errorf("Expected the rollup to point at the copied check, got %+v", rollups)
+	}
+	if _, err := to.GetUser(ctx, "admin"); err != nil {
+		t.Errorf("Expected the user to be copied, got error: %s", err)
+	}
+}
@@ -0,0 +1,463 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases"
+)
+
+// Memory keeps everything in memory. Nothing survives a restart, which makes
+// it useful for tests and for trying gogios out
+type Memory struct {
+	mu sync.RWMutex
+
+	checks  map[uint]gogios.Check
+	titles  map[string]uint
+	history map[uint]gogios.CheckHistory
+	rollups map[uint]gogios.CheckHistoryRollup
+	users   map[string]gogios.User
+
+	// The last ID handed out for each kind of row
+	lastCheck, lastHistory, lastRollup, lastUser uint
+}
+
+var sampleConfig = `
+  ## Keeps checks, history and users in memory only. Everything is lost
+  ## when gogios stops, so this is meant for testing
+`
+
+// SampleConfig returns the default config for Memory
+func (m *Memory) SampleConfig() string {
+	return sampleConfig
+}
+
+// SubConfig returns the default config ready for variable substitution
+func (m *Memory) SubConfig() string {
+	return sampleConfig
+}
+
+// Description returns a brief explanation of the database
+func (m *Memory) Description() string {
+	return "Keep check data in memory, for tests and short lived runs"
+}
+
+// Init empties the database
+func (m *Memory) Init() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checks = map[uint]gogios.Check{}
+	m.titles = map[string]uint{}
+	m.history = map[uint]gogios.CheckHistory{}
+	m.rollups = map[uint]gogios.CheckHistoryRollup{}
+	m.users = map[string]gogios.User{}
+
+	return nil
+}
+
+// Close does nothing, the data is kept until the next Init
+func (m *Memory) Close() error {
+	return nil
+}
+
+// ready makes sure Init has been called and the context is not done
+func (m *Memory) ready(ctx context.Context) error {
+	if m.checks == nil {
+		return fmt.Errorf("database has not been initialized")
+	}
+
+	return ctx.Err()
+}
+
+// AddCheck makes sure an entry exists for the check and then adds to its history
+func (m *Memory) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if check.ID == 0 {
+		if _, ok := m.titles[check.Title]; ok {
+			return fmt.Errorf("check %q already exists", check.Title)
+		}
+
+		m.lastCheck++
+		check.ID = m.lastCheck
+		check.CreatedAt = now
+	} else {
+		existing, ok := m.checks[check.ID]
+		if !ok {
+			return fmt.Errorf("check %q: %w", check.Title, gogios.ErrNotFound)
+		}
+		delete(m.titles, existing.Title)
+		check.CreatedAt = existing.CreatedAt
+	}
+	check.UpdatedAt = now
+
+	m.checks[check.ID] = check
+	m.titles[check.Title] = check.ID
+
+	checkID, asof, status := check.ID, check.Asof, check.Status
+	m.lastHistory++
+	data := gogios.CheckHistory{
+		CheckID:   &checkID,
+		Asof:      &asof,
+		Output:    output.Stdout,
+		Status:    &status,
+		Duration:  check.Duration,
+		ExitCode:  check.ExitCode,
+		Stderr:    output.Stderr,
+		Truncated: output.Truncated,
+	}
+	data.ID = m.lastHistory
+	data.CreatedAt = now
+	data.UpdatedAt = now
+	m.history[data.ID] = data
+
+	return nil
+}
+
+// DeleteCheck removes a check based on the title or ID. Its history is kept
+func (m *Memory) DeleteCheck(ctx context.Context, check gogios.Check, field string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	var id uint
+	switch field {
+	case "title":
+		id = m.titles[check.Title]
+	case "id":
+		id = check.ID
+	default:
+		return gogios.ErrInvalidField
+	}
+
+	existing, ok := m.checks[id]
+	if !ok {
+		return fmt.Errorf("check %q: %w", check.Title, gogios.ErrNotFound)
+	}
+	delete(m.titles, existing.Title)
+	delete(m.checks, id)
+
+	return nil
+}
+
+// GetCheck returns a single check. Searches using field (title or id)
+func (m *Memory) GetCheck(ctx context.Context, searchField, searchType string) (gogios.Check, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.ready(ctx); err != nil {
+		return gogios.Check{}, err
+	}
+
+	var id uint
+	switch searchType {
+	case "title":
+		id = m.titles[searchField]
+	case "id":
+		n, _ := strconv.ParseUint(searchField, 10, 64)
+		id = uint(n)
+	default:
+		return gogios.Check{}, gogios.ErrInvalidField
+	}
+
+	check, ok := m.checks[id]
+	if !ok {
+		return gogios.Check{}, fmt.Errorf("check %s %q: %w", searchType, searchField, gogios.ErrNotFound)
+	}
+
+	return check, nil
+}
+
+// GetAllChecks returns every check, ordered by ID
+func (m *Memory) GetAllChecks(ctx context.Context) ([]gogios.Check, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := []gogios.Check{}
+	if err := m.ready(ctx); err != nil {
+		return data, err
+	}
+
+	for _, check := range m.checks {
+		data = append(data, check)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].ID < data[j].ID
+	})
+
+	return data, nil
+}
+
+// GetCheckHistory returns $amount of rows of history for a check, newest first
+func (m *Memory) GetCheckHistory(ctx context.Context, check gogios.Check, amount int) ([]gogios.CheckHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := []gogios.CheckHistory{}
+	if err := m.ready(ctx); err != nil {
+		return data, err
+	}
+
+	for _, row := range m.history {
+		if *row.CheckID == check.ID {
+			data = append(data, row)
+		}
+	}
+	sortHistory(data)
+
+	// Newest first
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	if amount > 0 && len(data) > amount {
+		data = data[:amount]
+	}
+
+	return data, nil
+}
+
+// GetHistoryBefore returns up to limit history rows older than before, oldest first
+func (m *Memory) GetHistoryBefore(ctx context.Context, before time.Time, limit int) ([]gogios.CheckHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := []gogios.CheckHistory{}
+	if err := m.ready(ctx); err != nil {
+		return data, err
+	}
+
+	for _, row := range m.history {
+		if row.Asof.Before(before) {
+			data = append(data, row)
+		}
+	}
+	sortHistory(data)
+
+	if limit > 0 && len(data) > limit {
+		data = data[:limit]
+	}
+
+	return data, nil
+}
+
+// sortHistory orders history oldest first
+func sortHistory(data []gogios.CheckHistory) {
+	sort.Slice(data, func(i, j int) bool {
+		if !data[i].Asof.Equal(*data[j].Asof) {
+			return data[i].Asof.Before(*data[j].Asof)
+		}
+		return data[i].ID < data[j].ID
+	})
+}
+
+// DeleteHistory removes history rows by ID
+func (m *Memory) DeleteHistory(ctx context.Context, ids []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		delete(m.history, id)
+	}
+
+	return nil
+}
+
+// GetRollups returns the rollups of a period that start within [from, to)
+func (m *Memory) GetRollups(ctx context.Context, period string, from, to time.Time) ([]gogios.CheckHistoryRollup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := []gogios.CheckHistoryRollup{}
+	if err := m.ready(ctx); err != nil {
+		return data, err
+	}
+
+	for _, rollup := range m.rollups {
+		if rollup.Period == period && !rollup.Start.Before(from) && rollup.Start.Before(to) {
+			data = append(data, rollup)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if !data[i].Start.Equal(data[j].Start) {
+			return data[i].Start.Before(data[j].Start)
+		}
+		return data[i].CheckID < data[j].CheckID
+	})
+
+	return data, nil
+}
+
+// SaveRollups inserts new rollups and updates existing ones
+func (m *Memory) SaveRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	m.saveRollups(rollups)
+
+	return nil
+}
+
+// saveRollups stores the rollups, the caller holds the lock
+func (m *Memory) saveRollups(rollups []gogios.CheckHistoryRollup) {
+	now := time.Now()
+	for _, rollup := range rollups {
+		if existing, ok := m.rollups[rollup.ID]; ok {
+			rollup.CreatedAt = existing.CreatedAt
+		} else {
+			if rollup.ID == 0 {
+				m.lastRollup++
+				rollup.ID = m.lastRollup
+			}
+			rollup.CreatedAt = now
+		}
+		rollup.UpdatedAt = now
+
+		m.rollups[rollup.ID] = rollup
+	}
+}
+
+// DeleteRollups removes rollups by ID
+func (m *Memory) DeleteRollups(ctx context.Context, ids []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		delete(m.rollups, id)
+	}
+
+	return nil
+}
+
+// ReplaceHistoryWithRollups saves the rollups and removes the history rows and
+// rollups they replace under one lock
+func (m *Memory) ReplaceHistoryWithRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup, historyIDs, rollupIDs []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	m.saveRollups(rollups)
+	for _, id := range historyIDs {
+		delete(m.history, id)
+	}
+	for _, id := range rollupIDs {
+		delete(m.rollups, id)
+	}
+
+	return nil
+}
+
+// AddUser inserts a new user into the database
+func (m *Memory) AddUser(ctx context.Context, user gogios.User) error {
+	if user.Username == "" || user.Password == "" {
+		return gogios.ErrInvalidUser
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := m.users[user.Username]; ok {
+		return fmt.Errorf("user %q already exists", user.Username)
+	}
+
+	m.lastUser++
+	user.ID = m.lastUser
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	m.users[user.Username] = user
+
+	return nil
+}
+
+// DeleteUser removes the user, and with them their password
+func (m *Memory) DeleteUser(ctx context.Context, user gogios.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.ready(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := m.users[user.Username]; !ok {
+		return fmt.Errorf("user %q: %w", user.Username, gogios.ErrNotFound)
+	}
+	delete(m.users, user.Username)
+
+	return nil
+}
+
+// GetUser looks up a user by username and returns their struct
+func (m *Memory) GetUser(ctx context.Context, user string) (*gogios.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.ready(ctx); err != nil {
+		return &gogios.User{}, err
+	}
+
+	data, ok := m.users[user]
+	if !ok {
+		return &data, fmt.Errorf("user %q: %w", user, gogios.ErrNotFound)
+	}
+
+	return &data, nil
+}
+
+// GetAllUsers returns every user, ordered by ID
+func (m *Memory) GetAllUsers(ctx context.Context) ([]gogios.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := []gogios.User{}
+	if err := m.ready(ctx); err != nil {
+		return data, err
+	}
+
+	for _, user := range m.users {
+		data = append(data, user)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].ID < data[j].ID
+	})
+
+	return data, nil
+}
+
+func init() {
+	databases.Add("memory", func() gogios.Database {
+		return &Memory{}
+	})
+}
@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) gogios.Database {
+		db := &Memory{}
+		if err := db.Init(); err != nil {
+			t.Fatalf("Init failed, got error: %s", err)
+		}
+
+		return db
+	})
+}
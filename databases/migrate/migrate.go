@@ -0,0 +1,152 @@
+// Package migrate holds the versioned schema migrations shared by the SQL
+// databases. Every applied migration is recorded in the schema_migrations
+// table, so a database always knows which version of the schema it has.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a single step of the schema. Migrations are never edited once
+// released; changes to the schema are made by appending a new one.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// schemaMigration is a row of the schema_migrations table
+type schemaMigration struct {
+	Version     int `gorm:"primary_key;auto_increment:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Latest returns the schema version this build of gogios expects
+func Latest() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// applied reads the schema_migrations table, creating it if needed
+func applied(ctx context.Context, db *gorm.DB) (map[int]schemaMigration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+
+	result := map[int]schemaMigration{}
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+
+	return result, nil
+}
+
+// Status lists every known migration along with any unknown ones that have
+// been applied by a newer version of gogios
+func Status(ctx context.Context, db *gorm.DB) ([]gogios.MigrationStatus, error) {
+	done, err := applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []gogios.MigrationStatus
+	for _, m := range migrations {
+		status := gogios.MigrationStatus{Version: m.Version, Description: m.Description}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(done, m.Version)
+		}
+		result = append(result, status)
+	}
+
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		result = append(result, gogios.MigrationStatus{Version: row.Version, Description: row.Description, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// Up applies every pending migration in order. Each one runs in its own
+// transaction together with its schema_migrations row, so a failed migration
+// leaves the database at the previous version. A database that has been
+// migrated by a newer version of gogios is refused rather than touched.
+func Up(ctx context.Context, db *gorm.DB) ([]gogios.MigrationStatus, error) {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if status.Unknown {
+			return nil, fmt.Errorf("database has schema version %d but this gogios only knows up to %d", status.Version, Latest())
+		}
+	}
+
+	var ran []gogios.MigrationStatus
+	for i, m := range migrations {
+		if statuses[i].AppliedAt != nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return ran, err
+		}
+
+		now := time.Now()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&schemaMigration{Version: m.Version, Description: m.Description, AppliedAt: now}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+
+		ran = append(ran, gogios.MigrationStatus{Version: m.Version, Description: m.Description, AppliedAt: &now})
+	}
+
+	return ran, nil
+}
+
+// createTable creates the table for model unless it already exists
+func createTable(tx *gorm.DB, model interface{}) error {
+	if tx.HasTable(model) {
+		return nil
+	}
+
+	return tx.CreateTable(model).Error
+}
+
+// addColumn adds a column unless it already exists
+func addColumn(tx *gorm.DB, table, column, sqlType string) error {
+	if tx.Dialect().HasColumn(table, column) {
+		return nil
+	}
+
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tx.Dialect().Quote(table), tx.Dialect().Quote(column), sqlType)).Error
+}
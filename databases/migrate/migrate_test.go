@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	// Sqlite bindings for GORM
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func openSqlite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "gogios.db"))
+	if err != nil {
+		t.Fatalf("Could not open database, got error: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestUpFromEmpty(t *testing.T) {
+	ctx := context.Background()
+	db := openSqlite(t)
+
+	ran, err := Up(ctx, db)
+	if err != nil {
+		t.Fatalf("Up failed, got error: %s", err)
+	}
+	if len(ran) != len(migrations) {
+		t.Errorf("Expected %d migrations to run, got %d", len(migrations), len(ran))
+	}
+
+	ran, err = Up(ctx, db)
+	if err != nil || len(ran) != 0 {
+		t.Errorf("Expected nothing to run the second time, got %v and %v", ran, err)
+	}
+
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		t.Fatalf("Status failed, got error: %s", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Unknown {
+			t.Errorf("Expected migration %d to be applied, got %+v", status.Version, status)
+		}
+	}
+}
+
+func TestUpFromUnversioned(t *testing.T) {
+	ctx := context.Background()
+	db := openSqlite(t)
+
+	// Databases made before migrations existed only have the original tables
+	if err := db.AutoMigrate(&userV1{}, &checkV1{}, &checkHistoryV1{}).Error; err != nil {
+		t.Fatalf("Could not create tables, got error: %s", err)
+	}
+	if err := db.Create(&checkV1{Title: "ping", Asof: time.Now()}).Error; err != nil {
+		t.Fatalf("Could not add check, got error: %s", err)
+	}
+
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatalf("Up failed, got error: %s", err)
+	}
+
+	var duration int64 = -1
+	if err := db.Table("checks").Where("title = ?", "ping").Select("duration").Row().Scan(&duration); err != nil {
+		t.Fatalf("Could not read duration, got error: %s", err)
+	}
+	if duration != 0 {
+		t.Errorf("Expected existing rows to get a zero duration, got %d", duration)
+	}
+	if !db.HasTable("check_history_rollups") {
+		t.Error("Expected check_history_rollups to be created")
+	}
+}
+
+func TestUpRefusesNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openSqlite(t)
+
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatalf("Up failed, got error: %s", err)
+	}
+	if err := db.Create(&schemaMigration{Version: Latest() + 1, Description: "From the future", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("Could not record migration, got error: %s", err)
+	}
+
+	if _, err := Up(ctx, db); err == nil {
+		t.Error("Expected Up to refuse a newer schema")
+	}
+
+	statuses, _ := Status(ctx, db)
+	if last := statuses[len(statuses)-1]; !last.Unknown || last.Version != Latest()+1 {
+		t.Errorf("Expected the newer migration to be reported as unknown, got %+v", last)
+	}
+}
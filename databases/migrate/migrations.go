@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrations is the full history of the schema, oldest first. The structs
+// used here are snapshots of the models at the time of each migration, so
+// later changes to the models in the gogios package do not alter them.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create users, checks and check_histories",
+		Up:          createInitialTables,
+	},
+	{
+		Version:     2,
+		Description: "Add run durations and check_history_rollups",
+		Up:          addDurationsAndRollups,
+	},
+	{
+		Version:     3,
+		Description: "Add exit codes, stderr and truncation to history",
+		Up:          addExitCodesAndStderr,
+	},
+	{
+		Version:     4,
+		Description: "Add certificate expiry to checks",
+		Up:          addCertificateExpiry,
+	},
+	{
+		Version:     5,
+		Description: "Add tags to checks",
+		Up:          addTags,
+	},
+}
+
+type userV1 struct {
+	gorm.Model
+
+	Name     string `gorm:"size:255"`
+	Username string `gorm:"size:30;unique;not null"`
+	Password string `gorm:"not null"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type checkV1 struct {
+	gorm.Model
+
+	Title      string `gorm:"size:255;unique;not null"`
+	Command    string
+	Expected   string
+	Status     string
+	GoodCount  int
+	TotalCount int
+	Asof       time.Time
+}
+
+func (checkV1) TableName() string { return "checks" }
+
+type checkHistoryV1 struct {
+	gorm.Model
+
+	CheckID *uint
+	Asof    *time.Time
+	Output  string `gorm:"type:varchar(1250)"`
+	Status  *string
+}
+
+func (checkHistoryV1) TableName() string { return "check_histories" }
+
+// createInitialTables creates the tables that gogios used to make with
+// AutoMigrate. Databases from before migrations existed already have them, in
+// which case any missing columns are filled in and the version is recorded.
+func createInitialTables(tx *gorm.DB) error {
+	newTables := !tx.HasTable(&checkHistoryV1{})
+
+	err := tx.AutoMigrate(&userV1{}, &checkV1{}, &checkHistoryV1{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Sqlite cannot add constraints to an existing table
+	if newTables && tx.Dialect().GetName() != "sqlite3" {
+		return tx.Model(&checkHistoryV1{}).AddForeignKey("check_id", "checks(id)", "RESTRICT", "RESTRICT").Error
+	}
+
+	return nil
+}
+
+type checkHistoryRollupV2 struct {
+	gorm.Model
+
+	CheckID     uint      `gorm:"index"`
+	Period      string    `gorm:"size:10;index"`
+	Start       time.Time `gorm:"index"`
+	Up          int
+	Down        int
+	AvgDuration time.Duration
+}
+
+func (checkHistoryRollupV2) TableName() string { return "check_history_rollups" }
+
+// addDurationsAndRollups adds the columns and table used by history retention
+func addDurationsAndRollups(tx *gorm.DB) error {
+	if err := addColumn(tx, "checks", "duration", "bigint NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "check_histories", "duration", "bigint NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	return createTable(tx, &checkHistoryRollupV2{})
+}
+
+// addExitCodesAndStderr adds the columns that keep stdout and stderr apart.
+// Existing rows get an exit code of 0 and no stderr, since both used to be
+// folded into the output
+func addExitCodesAndStderr(tx *gorm.DB) error {
+	if err := addColumn(tx, "checks", "exit_code", "integer NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "check_histories", "exit_code", "integer NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "check_histories", "stderr", "varchar(1250) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	return addColumn(tx, "check_histories", "truncated", "boolean NOT NULL DEFAULT false")
+}
+
+// addCertificateExpiry adds the column tls checks record the expiry date of
+// the certificate in. It is null for every other check
+func addCertificateExpiry(tx *gorm.DB) error {
+	sqlType := "datetime"
+	switch tx.Dialect().GetName() {
+	case "postgres":
+		sqlType = "timestamp with time zone"
+	case "mysql":
+		sqlType = "DATETIME NULL"
+	}
+
+	return addColumn(tx, "checks", "expires", sqlType)
+}
+
+// addTags adds the column that holds the comma separated tags of a check
+func addTags(tx *gorm.DB) error {
+	return addColumn(tx, "checks", "tags", "varchar(255) NOT NULL DEFAULT ''")
+}
@@ -1,12 +1,13 @@
 package mysql
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/bkasin/gogios"
 	"github.com/bkasin/gogios/databases"
+	"github.com/bkasin/gogios/databases/gormdb"
+	"github.com/bkasin/gogios/helpers"
 	"github.com/jinzhu/gorm"
 
 	// MySQL bindings for GORM
@@ -20,6 +21,9 @@ type MySQL struct {
 	User     string
 	Password string
 	Database string
+
+	databases.Pool
+	gormdb.Store
 }
 
 var sampleConfig = `
@@ -33,6 +37,13 @@ var sampleConfig = `
 
   ## Name of the database that will be used
   database = "gogios"
+
+  ## Connections are opened once and shared by every check. Zero means no limit
+  # max_open_conns = 10
+  # max_idle_conns = 5
+  ## How long a connection may be reused, and how long it may sit idle
+  # conn_max_lifetime = "30m"
+  # conn_max_idle_time = "5m"
 `
 
 var subConfig = `
@@ -46,6 +57,13 @@ var subConfig = `
 
   ## Name of the database that will be used
   database = "%s"
+
+  ## Connections are opened once and shared by every check. Zero means no limit
+  # max_open_conns = 10
+  # max_idle_conns = 5
+  ## How long a connection may be reused, and how long it may sit idle
+  # conn_max_lifetime = "30m"
+  # conn_max_idle_time = "5m"
 `
 
 // SampleConfig returns the default config for MySQL
@@ -63,180 +81,26 @@ func (m *MySQL) Description() string {
 	return "Output check data to a MySQL database"
 }
 
-func (m *MySQL) openConnection() (*gorm.DB, error) {
-	db, err := gorm.Open("mysql", m.User+":"+m.Password+"@("+m.Host+":"+strconv.Itoa(m.Port)+")/"+m.Database+"?charset=utf8&parseTime=True&loc=Local")
-
-	return db, err
-}
-
-// AddCheck makes sure an entry exists for the check and then adds to its history
-func (m *MySQL) AddCheck(check gogios.Check, output string) error {
-	db, err := m.openConnection()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	data := gogios.CheckHistory{CheckID: &check.ID, Asof: &check.Asof, Output: output, Status: &check.Status}
-
-	if db.NewRecord(check) {
-		db.Create(&check)
-		id, err := m.GetCheck(check.Title, "title")
-		if err != nil {
-			return err
-		}
-		data.CheckID = &id.ID
-		db.Create(&data)
-	} else {
-		db.Model(check).Updates(&check)
-		db.Create(&data)
-	}
-
-	return nil
-}
-
-// DeleteCheck will remove a row from the check table based on the ID
-func (m *MySQL) DeleteCheck(check gogios.Check, field string) error {
-	db, err := m.openConnection()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	switch search := field; search {
-	case "title":
-		db.Where("title = ?", check.Title).Delete(&check)
-	case "id":
-		db.Delete(&check)
-	default:
-		err = errors.New("field needs to be title or id")
-		return err
-	}
-
-	return nil
-}
-
-// GetCheck returns a single row. Searches using field (title or id) and returns
-// the last record that matches
-func (m *MySQL) GetCheck(searchField, searchType string) (gogios.Check, error) {
-	lastRow := gogios.Check{}
-
-	db, err := m.openConnection()
-	if err != nil {
-		return lastRow, err
-	}
-	defer db.Close()
-
-	switch search := searchType; search {
-	case "title":
-		db.Where("title = ?", searchField).Last(&lastRow)
-	case "id":
-		db.Where("id = ?", searchField).Last(&lastRow)
-	default:
-		err = errors.New("searchType needs to be title or id")
-		return lastRow, err
-	}
-
-	return lastRow, nil
-}
-
-// GetAllChecks returns all the rows in the check table
-func (m *MySQL) GetAllChecks() ([]gogios.Check, error) {
-	data := []gogios.Check{}
-	db, err := m.openConnection()
-	if err != nil {
-		return data, err
-	}
-	defer db.Close()
-
-	db.Where("deleted_at IS NULL").Find(&data)
-
-	return data, nil
-}
-
-// GetCheckHistory returns $amount of rows of history for a check
-func (m *MySQL) GetCheckHistory(check gogios.Check, amount int) ([]gogios.CheckHistory, error) {
-	data := []gogios.CheckHistory{}
-	db, err := m.openConnection()
-	if err != nil {
-		return data, err
-	}
-	defer db.Close()
-
-	db.Raw("SELECT * FROM check_histories WHERE check_id = ? ORDER BY asof DESC LIMIT ?", check.ID, amount).Scan(&data)
-
-	return data, nil
-}
-
-// AddUser inserts a new user into the database
-func (m *MySQL) AddUser(user gogios.User) error {
-	db, err := m.openConnection()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	if user.Username == "" || user.Password == "" {
-		return errors.New("username or password was empty")
-	}
-
-	err = db.Create(&user).Error
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// DeleteUser sets the DeletedAt value of the user and blanks their password
-func (m *MySQL) DeleteUser(user gogios.User) error {
-	db, err := m.openConnection()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	db.Where("Username = ?", user.Username).Update("password", "")
-	db.Where("Username = ?", user.Username).Delete(&user)
-
-	return nil
-}
-
-// GetUser looks up a user by username and returns their struct
-func (m *MySQL) GetUser(user string) (*gogios.User, error) {
-	data := gogios.User{}
-	db, err := m.openConnection()
-	if err != nil {
-		return &data, err
-	}
-	defer db.Close()
-
-	if err := db.Where("Username = ?", user).First(&data).Error; err != nil {
-		fmt.Println(&data)
-		return &data, err
-	}
-
-	return &data, nil
-}
-
-// Init creates the database file and tables
+// Init opens the connection pool. The tables are created by Migrate
 func (m *MySQL) Init() error {
-	db, err := m.openConnection()
+	db, err := gorm.Open("mysql", m.User+":"+m.Password+"@("+m.Host+":"+strconv.Itoa(m.Port)+")/"+m.Database+"?charset=utf8&parseTime=True&loc=Local")
 	if err != nil {
 		return err
 	}
-	defer db.Close()
-
-	if !db.HasTable(&gogios.CheckHistory{}) {
-		db.AutoMigrate(&gogios.User{}, &gogios.Check{})
-		db.AutoMigrate(&gogios.CheckHistory{}).AddForeignKey("check_id", "checks(id)", "RESTRICT", "RESTRICT")
-	}
+	m.ConfigurePool(db.DB())
 
-	return nil
+	return m.Open(db)
 }
 
 func init() {
 	databases.Add("mysql", func() gogios.Database {
-		return &MySQL{}
+		return &MySQL{
+			Pool: databases.Pool{
+				MaxOpenConns:    10,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: helpers.Duration{Duration: 30 * time.Minute},
+				ConnMaxIdleTime: helpers.Duration{Duration: 5 * time.Minute},
+			},
+		}
 	})
 }
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases"
+	"github.com/bkasin/gogios/databases/dbtest"
+)
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// The suite only runs against a real server when GOGIOS_TEST_MYSQL_HOST is
+// set. Every table in the database is dropped before each test.
+func TestConformance(t *testing.T) {
+	host := os.Getenv("GOGIOS_TEST_MYSQL_HOST")
+	if host == "" {
+		t.Skip("GOGIOS_TEST_MYSQL_HOST is not set")
+	}
+	port, err := strconv.Atoi(getenv("GOGIOS_TEST_MYSQL_PORT", "3306"))
+	if err != nil {
+		t.Fatalf("GOGIOS_TEST_MYSQL_PORT is not a number: %s", err)
+	}
+
+	dbtest.Run(t, func(t *testing.T) gogios.Database {
+		db := &MySQL{
+			Host:     host,
+			Port:     port,
+			User:     getenv("GOGIOS_TEST_MYSQL_USER", "root"),
+			Password: os.Getenv("GOGIOS_TEST_MYSQL_PASSWORD"),
+			Database: getenv("GOGIOS_TEST_MYSQL_DATABASE", "gogios_test"),
+			Pool:     databases.Pool{MaxOpenConns: 2},
+		}
+		if err := db.Init(); err != nil {
+			t.Fatalf("Init failed, got error: %s", err)
+		}
+
+		conn, err := db.DB(context.Background())
+		if err != nil {
+			t.Fatalf("Could not get the connection, got error: %s", err)
+		}
+		err = conn.DropTableIfExists("check_histories", "check_history_rollups", "checks", "users", "schema_migrations").Error
+		if err != nil {
+			t.Fatalf("Could not empty the database, got error: %s", err)
+		}
+
+		return db
+	})
+}
@@ -0,0 +1,24 @@
+package databases
+
+import (
+	"database/sql"
+
+	"github.com/bkasin/gogios/helpers"
+)
+
+// Pool holds the connection pool options shared by the SQL databases. Embed it
+// in a database's struct so the options are read from its config table.
+type Pool struct {
+	MaxOpenConns    int              `toml:"max_open_conns"`
+	MaxIdleConns    int              `toml:"max_idle_conns"`
+	ConnMaxLifetime helpers.Duration `toml:"conn_max_lifetime"`
+	ConnMaxIdleTime helpers.Duration `toml:"conn_max_idle_time"`
+}
+
+// ConfigurePool applies the pool options to an open connection
+func (p Pool) ConfigurePool(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetMaxIdleConns(p.MaxIdleConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime.Duration)
+	db.SetConnMaxIdleTime(p.ConnMaxIdleTime.Duration)
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases"
+	"github.com/bkasin/gogios/databases/dbtest"
+)
+
+// The suite only runs against a real server when GOGIOS_TEST_POSTGRES is set
+// to a connection string. Every table in the database is dropped before each
+// test.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("GOGIOS_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("GOGIOS_TEST_POSTGRES is not set")
+	}
+
+	dbtest.Run(t, func(t *testing.T) gogios.Database {
+		db := &Postgres{ConnectionString: dsn, Pool: databases.Pool{MaxOpenConns: 2}}
+		if err := db.Init(); err != nil {
+			t.Fatalf("Init failed, got error: %s", err)
+		}
+
+		conn, err := db.DB(context.Background())
+		if err != nil {
+			t.Fatalf("Could not get the connection, got error: %s", err)
+		}
+		err = conn.DropTableIfExists("check_histories", "check_history_rollups", "checks", "users", "schema_migrations").Error
+		if err != nil {
+			t.Fatalf("Could not empty the database, got error: %s", err)
+		}
+
+		return db
+	})
+}
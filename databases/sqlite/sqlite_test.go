@@ -0,0 +1,24 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases"
+	"github.com/bkasin/gogios/databases/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) gogios.Database {
+		db := &Sqlite{
+			DBFile: filepath.Join(t.TempDir(), "gogios.db"),
+			Pool:   databases.Pool{MaxOpenConns: 1, MaxIdleConns: 1},
+		}
+		if err := db.Init(); err != nil {
+			t.Fatalf("Init failed, got error: %s", err)
+		}
+
+		return db
+	})
+}
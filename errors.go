@@ -0,0 +1,14 @@
+package gogios
+
+import "errors"
+
+// Errors returned by Database implementations. Backends wrap them, so compare
+// with errors.Is.
+var (
+	// ErrNotFound means the database is reachable but has no matching record
+	ErrNotFound = errors.New("record not found")
+	// ErrInvalidField means a search or delete was asked to use an unknown field
+	ErrInvalidField = errors.New("field needs to be title or id")
+	// ErrInvalidUser means a user is missing its username or password
+	ErrInvalidUser = errors.New("username or password was empty")
+)
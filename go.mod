@@ -1,19 +1,28 @@
 module github.com/bkasin/gogios
 
 require (
+	github.com/go-asn1-ber/asn1-ber v1.5.5
+	github.com/go-ldap/ldap/v3 v3.4.6
+	github.com/go-sql-driver/mysql v1.7.1
 	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/google/logger v1.1.1
 	github.com/gorilla/mux v1.8.0
 	github.com/influxdata/toml v0.0.0-20180607005434-2a2e3012f7cf
 	github.com/jinzhu/gorm v1.9.16
+	github.com/lib/pq v1.10.9
 	github.com/nlopes/slack v0.6.0
+	github.com/pkg/sftp v1.13.6
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/crypto v0.13.0
+	golang.org/x/net v0.15.0
 )
 
 require (
-	github.com/go-sql-driver/mysql v1.7.1 // indirect
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
+	github.com/google/uuid v1.3.1 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/mattn/go-sqlite3 v2.0.1+incompatible // indirect
 	github.com/naoina/go-stringutil v0.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
@@ -1,20 +1,55 @@
 package helpers
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os/exec"
+	"time"
 
 	"github.com/google/logger"
 )
 
-// GetCommandOutput runs a command in a subshell and returns the output as a string
-func GetCommandOutput(logger *logger.Logger, command string, args []string) (output string) {
-	cmd := exec.Command(command, args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
+// CommandResult is what a command printed and how it exited
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int  // -1 if the command could not be started or was killed
+	TimedOut bool // The context ran out and the command was killed
+}
+
+// waitDelay is how long to wait for output after the command has been
+// killed, in case something outside its process group holds the pipes open
+const waitDelay = 2 * time.Second
+
+// RunCommand runs a command and returns its stdout and stderr separately,
+// along with its exit code. The command runs in its own process group, and
+// the whole group is killed once the context is done. Whatever it printed
+// until then is still returned
+func RunCommand(ctx context.Context, logger *logger.Logger, command string, args []string) CommandResult {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+	killProcessGroup(cmd)
+
+	err := cmd.Run()
+	result := CommandResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	// A command that finished just as the context ran out still counts
+	if err != nil && ctx.Err() != nil {
+		result.TimedOut = true
+		return result
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
 		logger.Errorf("cmd.Run() failed with %s\n", err)
-		return
 	}
-	sha := string(out)
 
-	return sha
+	return result
 }
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package helpers
+
+import "os/exec"
+
+// killProcessGroup leaves the default of killing only the command itself on
+// systems without process groups
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/logger"
+)
+
+func TestRunCommand(t *testing.T) {
+	log := logger.Init("CommandTest", false, false, io.Discard)
+	defer log.Close()
+	ctx := context.Background()
+
+	result := RunCommand(ctx, log, "/bin/sh", []string{"-c", "echo out; echo err >&2; exit 3"})
+	if result.Stdout != "out\n" || result.Stderr != "err\n" || result.ExitCode != 3 || result.TimedOut {
+		t.Errorf("Output was not kept apart, got: %+v", result)
+	}
+
+	result = RunCommand(ctx, log, "/nonexistent/command", nil)
+	if result.ExitCode != -1 {
+		t.Errorf("Expected an exit code of -1 for a command that can not start, got: %d", result.ExitCode)
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	log := logger.Init("CommandTest", false, false, io.Discard)
+	defer log.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep holds stdout open, so this only returns before
+	// waitDelay if the whole process group was killed
+	start := time.Now()
+	result := RunCommand(ctx, log, "/bin/sh", []string{"-c", "echo partial; sleep 30 & sleep 30"})
+	if elapsed := time.Since(start); elapsed >= waitDelay {
+		t.Errorf("Expected the process group to be killed, took %s", elapsed)
+	}
+
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("Expected the command to time out, got: %+v", result)
+	}
+	if result.Stdout != "partial\n" {
+		t.Errorf("Expected the partial output to be kept, got: %q", result.Stdout)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package helpers
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts the command in a new process group and makes
+// cancelling it kill the whole group, so anything the shell started dies too
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/bkasin/gogios"
 	"github.com/bkasin/gogios/databases"
 	"github.com/bkasin/gogios/helpers"
 	"github.com/bkasin/gogios/helpers/models"
 	"github.com/bkasin/gogios/notifiers"
+	"github.com/bkasin/gogios/outputs"
 	"github.com/influxdata/toml"
 	"github.com/influxdata/toml/ast"
 )
@@ -23,6 +26,7 @@ type Config struct {
 
 	Notifiers []*models.ActiveNotifier
 	Databases []*models.ActiveDatabase
+	Outputs   []*models.ActiveOutput
 }
 
 // OptionsConfig - General system options such as check interval
@@ -34,6 +38,26 @@ type OptionsConfig struct {
 
 	// Timeout for each check
 	Timeout helpers.Duration
+	// How many checks may run at once. 0 removes the limit
+	MaxConcurrentChecks int `toml:"max_concurrent_checks"`
+
+	// How many days of history to keep at each resolution. Raw results are
+	// rolled up into hourly rows, and hourly rows into daily rows. 0 keeps that
+	// resolution forever, and a raw_history_days of 0 disables pruning
+	RawHistoryDays    int `toml:"raw_history_days"`
+	HourlyHistoryDays int `toml:"hourly_history_days"`
+	DailyHistoryDays  int `toml:"daily_history_days"`
+	// How often the history pruner runs
+	PruneInterval helpers.Duration `toml:"prune_interval"`
+
+	// Apply pending database schema migrations at startup. When false gogios
+	// refuses to start until `gogios db migrate` has been run
+	AutoMigrate bool `toml:"auto_migrate"`
+
+	// How many writes to keep for a database that is down, and how often to
+	// check whether it is back
+	WriteQueueSize      int              `toml:"write_queue_size"`
+	HealthCheckInterval helpers.Duration `toml:"health_check_interval"`
 }
 
 // WebOptionsConfig - Options related to the web interface
@@ -73,6 +97,18 @@ func NewConfig() *Config {
 			Interval: helpers.Duration{Duration: 3 * time.Minute},
 			Verbose:  false,
 			Timeout:  helpers.Duration{Duration: 60 * time.Second},
+
+			MaxConcurrentChecks: 32,
+
+			RawHistoryDays:    0,
+			HourlyHistoryDays: 90,
+			DailyHistoryDays:  0,
+			PruneInterval:     helpers.Duration{Duration: time.Hour},
+
+			AutoMigrate: true,
+
+			WriteQueueSize:      10000,
+			HealthCheckInterval: helpers.Duration{Duration: 30 * time.Second},
 		},
 
 		WebOptions: &WebOptionsConfig{
@@ -92,6 +128,7 @@ func NewConfig() *Config {
 		},
 		Notifiers: make([]*models.ActiveNotifier, 0),
 		Databases: make([]*models.ActiveDatabase, 0),
+		Outputs:   make([]*models.ActiveOutput, 0),
 	}
 
 	return c
@@ -131,7 +168,7 @@ func (c *Config) GetConfig(config string) error {
 		}
 	}
 
-	// Notifiers and Databases
+	// Notifiers, Databases and Outputs
 	for name, val := range tbl.Fields {
 		subTable, ok := val.(*ast.Table)
 		if !ok {
@@ -166,6 +203,19 @@ func (c *Config) GetConfig(config string) error {
 					return fmt.Errorf("Unsupported config format: %s, file %s", databaseName, config)
 				}
 			}
+		case "outputs":
+			for outputName, val := range subTable.Fields {
+				switch outputSubTable := val.(type) {
+				case []*ast.Table:
+					for _, t := range outputSubTable {
+						if err = c.addOutput(outputName, t); err != nil {
+							return fmt.Errorf("Error parsing: %s, %s", config, err)
+						}
+					}
+				default:
+					return fmt.Errorf("Unsupported config format: %s, file %s", outputName, config)
+				}
+			}
 		default:
 			fmt.Printf("Unrecognized config option: %s", name)
 		}
@@ -178,7 +228,7 @@ func (c *Config) GetConfig(config string) error {
 func (c *Config) NotifierNames() []string {
 	var name []string
 	for _, notifier := range c.Notifiers {
-		name = append(name, notifier.Config.Name)
+		name = append(name, notifier.LogName())
 	}
 
 	return name
@@ -188,7 +238,17 @@ func (c *Config) NotifierNames() []string {
 func (c *Config) DatabaseNames() []string {
 	var name []string
 	for _, database := range c.Databases {
-		name = append(name, database.Config.Name)
+		name = append(name, database.LogName())
+	}
+
+	return name
+}
+
+// OutputNames returns a list of all configured outputs
+func (c *Config) OutputNames() []string {
+	var name []string
+	for _, output := range c.Outputs {
+		name = append(name, output.LogName())
 	}
 
 	return name
@@ -210,10 +270,38 @@ var optionsConfig = `
   verbose = false
 
   # Per check timeout in seconds
-  # If enough checks get stuck it is possible that the
-  # next round will start before the previous finishes
+  # Checks that run longer are killed along with anything they started
+  # A check can set its own "timeout" in the check list to override this
   timeout = "60s"
 
+  # How many checks may run at the same time. The rest wait in a
+  # queue, and a check that is still running when the next round
+  # starts is skipped for that round. 0 removes the limit
+  max_concurrent_checks = 32
+
+  # History retention in days. Results older than raw_history_days
+  # are rolled up into hourly up/down counts and average durations,
+  # hourly rows older than hourly_history_days into daily rows, and
+  # daily rows older than daily_history_days are deleted
+  # 0 keeps that resolution forever, and raw_history_days = 0 turns
+  # pruning off entirely
+  raw_history_days = 0
+  hourly_history_days = 90
+  daily_history_days = 0
+
+  # How often old history is rolled up and pruned
+  prune_interval = "1h"
+
+  # Upgrade the database schema automatically when gogios starts
+  # If false, gogios will not start until "gogios db migrate" is run
+  auto_migrate = true
+
+  # Reads use the first database that is up and writes go to all of
+  # them. Writes for a database that is down are queued, up to
+  # write_queue_size, and replayed once a health check finds it again
+  write_queue_size = 10000
+  health_check_interval = "30s"
+
 `
 
 var subOptionsConfig = `
@@ -226,10 +314,38 @@ var subOptionsConfig = `
   verbose = false
 
   # Per check timeout in seconds
-  # If enough checks get stuck it is possible that the
-  # next round will start before the previous finishes
+  # Checks that run longer are killed along with anything they started
+  # A check can set its own "timeout" in the check list to override this
   timeout = "%ss"
 
+  # How many checks may run at the same time. The rest wait in a
+  # queue, and a check that is still running when the next round
+  # starts is skipped for that round. 0 removes the limit
+  max_concurrent_checks = 32
+
+  # History retention in days. Results older than raw_history_days
+  # are rolled up into hourly up/down counts and average durations,
+  # hourly rows older than hourly_history_days into daily rows, and
+  # daily rows older than daily_history_days are deleted
+  # 0 keeps that resolution forever, and raw_history_days = 0 turns
+  # pruning off entirely
+  raw_history_days = 0
+  hourly_history_days = 90
+  daily_history_days = 0
+
+  # How often old history is rolled up and pruned
+  prune_interval = "1h"
+
+  # Upgrade the database schema automatically when gogios starts
+  # If false, gogios will not start until "gogios db migrate" is run
+  auto_migrate = true
+
+  # Reads use the first database that is up and writes go to all of
+  # them. Writes for a database that is down are queued, up to
+  # write_queue_size, and replayed once a health check finds it again
+  write_queue_size = 10000
+  health_check_interval = "30s"
+
 `
 
 var webConfig = `
@@ -314,6 +430,11 @@ var databaseHeader = `
 # Databases
 #
 ###########################
+
+# Every database accepts these options alongside its own:
+#   alias = ""      # Name used in logs and the API to tell instances apart
+#   enabled = true  # Set to false to keep the block without using it
+#   timeout = "10s" # Give up on a write after this long
 `
 
 var notifierHeader = `
@@ -323,6 +444,30 @@ var notifierHeader = `
 # Notifiers
 #
 ###########################
+
+# Every notifier accepts these options alongside its own:
+#   alias = ""               # Name used in logs, the API and "gogios notify -to"
+#   enabled = true           # Set to false to keep the block without using it
+#   filters = ["Web*"]       # Only notify about checks whose title matches a pattern
+#   timeout = "10s"          # Give up on a notification after this long
+#   min_severity = "warning" # ok, warning or critical
+`
+
+var outputHeader = `
+
+###########################
+#
+# Outputs
+#
+###########################
+
+# Outputs receive the results of every round of checks, for example to graph
+# them in a time-series database. Every output accepts these options alongside
+# its own:
+#   alias = ""         # Name used in logs and the API to tell instances apart
+#   enabled = true     # Set to false to keep the block without using it
+#   filters = ["Web*"] # Only write checks whose title matches a pattern
+#   timeout = "10s"    # Give up on a write after this long
 `
 
 // PrintSampleConfig prints the sample config
@@ -336,6 +481,9 @@ func PrintSampleConfig() {
 
 	fmt.Print(notifierHeader)
 	printNotifiers(true)
+
+	fmt.Print(outputHeader)
+	printOutputs(true)
 }
 
 // PrintSetupConfig returns a version of the config ready
@@ -374,7 +522,22 @@ func PrintSetupConfig(db string) string {
 		nfs += "\n\n"
 	}
 
-	printConfig := fmt.Sprint(header, subOptionsConfig, subWebConfig, databaseHeader, dbs, notifierHeader, nfs)
+	var onames []string
+	for oname := range outputs.Outputs {
+		onames = append(onames, oname)
+	}
+	sort.Strings(onames)
+
+	ops := ""
+	for _, oname := range onames {
+		creator := outputs.Outputs[oname]
+		output := creator()
+
+		ops += printConfig(oname, output, "outputs", true)
+		ops += "\n\n"
+	}
+
+	printConfig := fmt.Sprint(header, subOptionsConfig, subWebConfig, databaseHeader, dbs, notifierHeader, nfs, outputHeader, ops)
 	return printConfig
 }
 
@@ -408,13 +571,28 @@ func printDatabases(commented bool) {
 	}
 }
 
+func printOutputs(commented bool) {
+	var anames []string
+	for aname := range outputs.Outputs {
+		anames = append(anames, aname)
+	}
+	sort.Strings(anames)
+
+	for _, aname := range anames {
+		creator := outputs.Outputs[aname]
+		output := creator()
+
+		fmt.Print(printConfig(aname, output, "outputs", commented))
+	}
+}
+
 type data interface {
 	Description() string
 	SampleConfig() string
 	SubConfig() string
 }
 
-// printConfig returns the database or notifier section of the config as a string
+// printConfig returns the database, notifier or output section of the config as a string
 func printConfig(name string, d data, cat string, commented bool) string {
 	comment := ""
 	if commented {
@@ -473,10 +651,13 @@ func (c *Config) addNotifier(name string, table *ast.Table) error {
 	}
 	notifier := creator()
 
-	notifierConfig, err := buildNotifier(name, table)
+	notifierConfig, enabled, err := buildNotifier(name, table)
 	if err != nil {
 		return err
 	}
+	if !enabled {
+		return nil
+	}
 
 	if err := toml.UnmarshalTable(table, notifier); err != nil {
 		return err
@@ -496,10 +677,13 @@ func (c *Config) addDatabase(name string, table *ast.Table) error {
 	}
 	database := creator()
 
-	databaseConfig, err := buildDatabase(name, table)
+	databaseConfig, enabled, err := buildDatabase(name, table)
 	if err != nil {
 		return err
 	}
+	if !enabled {
+		return nil
+	}
 
 	if err := toml.UnmarshalTable(table, database); err != nil {
 		return err
@@ -512,14 +696,172 @@ func (c *Config) addDatabase(name string, table *ast.Table) error {
 	return nil
 }
 
-func buildNotifier(name string, tbl *ast.Table) (*models.NotifierConfig, error) {
-	conf := &models.NotifierConfig{Name: name}
+func (c *Config) addOutput(name string, table *ast.Table) error {
+	creator, ok := outputs.Outputs[name]
+	if !ok {
+		return fmt.Errorf("Undefined but requested output: %s", name)
+	}
+	output := creator()
+
+	outputConfig, enabled, err := buildOutput(name, table)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	if err := toml.UnmarshalTable(table, output); err != nil {
+		return err
+	}
+
+	rf := models.NewActiveOutput(output, outputConfig)
+
+	c.Outputs = append(c.Outputs, rf)
+
+	return nil
+}
+
+// commonOptions are the per-instance options that every notifier, database and
+// output table may set. They are removed from the table before it is handed to the
+// plugin so plugins do not need to declare them.
+type commonOptions struct {
+	Alias       string
+	Enabled     bool
+	Filters     []string
+	Timeout     time.Duration
+	MinSeverity int
+}
+
+func parseCommonOptions(tbl *ast.Table) (*commonOptions, error) {
+	opts := &commonOptions{Enabled: true}
+
+	if node, ok := tbl.Fields["alias"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			str, ok := kv.Value.(*ast.String)
+			if !ok {
+				return nil, fmt.Errorf("line %d: alias must be a string", kv.Line)
+			}
+			opts.Alias = str.Value
+		}
+	}
+
+	if node, ok := tbl.Fields["enabled"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			b, ok := kv.Value.(*ast.Boolean)
+			if !ok {
+				return nil, fmt.Errorf("line %d: enabled must be true or false", kv.Line)
+			}
+			opts.Enabled = b.Value == "true"
+		}
+	}
+
+	if node, ok := tbl.Fields["filters"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			ary, ok := kv.Value.(*ast.Array)
+			if !ok {
+				return nil, fmt.Errorf("line %d: filters must be an array of strings", kv.Line)
+			}
+			for _, elem := range ary.Value {
+				str, ok := elem.(*ast.String)
+				if !ok {
+					return nil, fmt.Errorf("line %d: filters must be an array of strings", kv.Line)
+				}
+				if _, err := path.Match(str.Value, ""); err != nil {
+					return nil, fmt.Errorf("line %d: invalid filter %q: %s", kv.Line, str.Value, err)
+				}
+				opts.Filters = append(opts.Filters, str.Value)
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["timeout"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			var d helpers.Duration
+			if err := d.UnmarshalTOML([]byte(kv.Value.Source())); err != nil {
+				return nil, fmt.Errorf("line %d: invalid timeout: %s", kv.Line, err)
+			}
+			opts.Timeout = d.Duration
+		}
+	}
+
+	if node, ok := tbl.Fields["min_severity"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			str, ok := kv.Value.(*ast.String)
+			if !ok {
+				return nil, fmt.Errorf("line %d: min_severity must be a string", kv.Line)
+			}
+			severity, err := gogios.ParseSeverity(str.Value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", kv.Line, err)
+			}
+			opts.MinSeverity = severity
+		}
+	}
+
+	delete(tbl.Fields, "alias")
+	delete(tbl.Fields, "enabled")
+	delete(tbl.Fields, "filters")
+	delete(tbl.Fields, "timeout")
+	delete(tbl.Fields, "min_severity")
+
+	return opts, nil
+}
+
+func buildNotifier(name string, tbl *ast.Table) (*models.NotifierConfig, bool, error) {
+	opts, err := parseCommonOptions(tbl)
+	if err != nil {
+		return nil, false, fmt.Errorf("notifier %s: %s", name, err)
+	}
+
+	conf := &models.NotifierConfig{
+		Name:        name,
+		Alias:       opts.Alias,
+		Filters:     opts.Filters,
+		Timeout:     opts.Timeout,
+		MinSeverity: opts.MinSeverity,
+	}
+
+	return conf, opts.Enabled, nil
+}
+
+func buildDatabase(name string, tbl *ast.Table) (*models.DatabaseConfig, bool, error) {
+	opts, err := parseCommonOptions(tbl)
+	if err != nil {
+		return nil, false, fmt.Errorf("database %s: %s", name, err)
+	}
+
+	// Every database stores every check, so routing options make no sense here
+	if len(opts.Filters) > 0 || opts.MinSeverity != gogios.SeverityOK {
+		return nil, false, fmt.Errorf("database %s: filters and min_severity are only supported by notifiers", name)
+	}
+
+	conf := &models.DatabaseConfig{
+		Name:    name,
+		Alias:   opts.Alias,
+		Timeout: opts.Timeout,
+	}
 
-	return conf, nil
+	return conf, opts.Enabled, nil
 }
 
-func buildDatabase(name string, tbl *ast.Table) (*models.DatabaseConfig, error) {
-	conf := &models.DatabaseConfig{Name: name}
+func buildOutput(name string, tbl *ast.Table) (*models.OutputConfig, bool, error) {
+	opts, err := parseCommonOptions(tbl)
+	if err != nil {
+		return nil, false, fmt.Errorf("output %s: %s", name, err)
+	}
+
+	// Outputs record every status, so there is nothing for a severity to skip
+	if opts.MinSeverity != gogios.SeverityOK {
+		return nil, false, fmt.Errorf("output %s: min_severity is only supported by notifiers", name)
+	}
+
+	conf := &models.OutputConfig{
+		Name:    name,
+		Alias:   opts.Alias,
+		Filters: opts.Filters,
+		Timeout: opts.Timeout,
+	}
 
-	return conf, nil
+	return conf, opts.Enabled, nil
 }
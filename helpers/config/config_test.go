@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	_ "github.com/bkasin/gogios/databases/all"
+	_ "github.com/bkasin/gogios/notifiers/all"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "gogios.toml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Could not write config, got error: %s", err)
+	}
+
+	return path
+}
+
+func TestCommonOptions(t *testing.T) {
+	path := writeConfig(t, `
+[[databases.sqlite]]
+  alias = "local"
+  timeout = "5s"
+  db_file = "/tmp/gogios.db"
+
+[[notifiers.telegram]]
+  alias = "ops"
+  filters = ["Web*", "DNS"]
+  min_severity = "critical"
+  timeout = "3s"
+  api = "key"
+  chats = ["1"]
+
+[[notifiers.telegram]]
+  alias = "off"
+  enabled = false
+  api = "key"
+`)
+
+	c := NewConfig()
+	if err := c.GetConfig(path); err != nil {
+		t.Fatalf("Config parsing failed, got error: %s", err)
+	}
+
+	if len(c.Databases) != 1 || c.Databases[0].LogName() != "sqlite (local)" || c.Databases[0].Config.Timeout != 5*time.Second {
+		t.Errorf("Database options were not applied, got: %+v", c.Databases[0].Config)
+	}
+
+	if len(c.Notifiers) != 1 {
+		t.Fatalf("Disabled notifier was not dropped, got %d notifiers", len(c.Notifiers))
+	}
+
+	n := c.Notifiers[0]
+	if n.LogName() != "telegram (ops)" || n.Config.Timeout != 3*time.Second || n.Config.MinSeverity != gogios.SeverityCritical {
+		t.Errorf("Notifier options were not applied, got: %+v", n.Config)
+	}
+
+	if !n.Accepts("Web server", gogios.StatusFailed, gogios.StatusSuccess) {
+		t.Errorf("Notifier should accept a failing check that matches its filters")
+	}
+	if !n.Accepts("DNS", gogios.StatusSuccess, gogios.StatusFailed) {
+		t.Errorf("Notifier should accept the recovery of a critical check")
+	}
+	if n.Accepts("SSH", gogios.StatusFailed, gogios.StatusSuccess) {
+		t.Errorf("Notifier should not accept a check outside its filters")
+	}
+	if n.Accepts("Web server", gogios.StatusWarning, gogios.StatusSuccess) {
+		t.Errorf("Notifier should not accept a change below its minimum severity")
+	}
+}
+
+func TestDatabaseRoutingOptions(t *testing.T) {
+	path := writeConfig(t, `
+[[databases.sqlite]]
+  filters = ["Web*"]
+  db_file = "/tmp/gogios.db"
+`)
+
+	c := NewConfig()
+	if err := c.GetConfig(path); err == nil {
+		t.Errorf("Filters on a database should be rejected")
+	}
+}
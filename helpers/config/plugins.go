@@ -1,21 +1,103 @@
 package config
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"strings"
 
-// InitPlugins calls the Init() function on any enabled notifiers and databases
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/manager"
+)
+
+// DB spreads reads and writes over the configured databases. It is set up by
+// InitPlugins
+var DB *manager.Manager
+
+// InitPlugins calls the Init() function on any enabled notifiers, databases and
+// outputs and brings the database schemas up to date
 func InitPlugins() error {
 	for _, d := range Conf.Databases {
 		err := d.Database.Init()
 		if err != nil {
-			return fmt.Errorf("could not initialize database %s: %v", d.Config.Name, err)
+			return fmt.Errorf("could not initialize database %s: %v", d.LogName(), err)
+		}
+
+		if migrator, ok := d.Database.(gogios.Migrator); ok {
+			err = checkSchema(context.Background(), migrator, Conf.Options.AutoMigrate)
+			if err != nil {
+				return fmt.Errorf("database %s: %v", d.LogName(), err)
+			}
 		}
 	}
+	DB = manager.New(Conf.Databases, Conf.Options.WriteQueueSize)
+
 	for _, n := range Conf.Notifiers {
 		err := n.Notifier.Init()
 		if err != nil {
-			return fmt.Errorf("could not initialize notifier %s: %v", n.Config.Name, err)
+			return fmt.Errorf("could not initialize notifier %s: %v", n.LogName(), err)
+		}
+	}
+
+	for _, o := range Conf.Outputs {
+		err := o.Output.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize output %s: %v", o.LogName(), err)
 		}
 	}
 
 	return nil
 }
+
+// ClosePlugins calls the Close() function on any enabled databases and outputs
+// so their connections are released cleanly
+func ClosePlugins() error {
+	var failed []string
+	for _, d := range Conf.Databases {
+		err := d.Database.Close()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", d.LogName(), err))
+		}
+	}
+
+	for _, o := range Conf.Outputs {
+		err := o.Output.Close()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", o.LogName(), err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not close plugins: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// checkSchema applies pending migrations when auto is set, and otherwise
+// fails if the schema is not up to date
+func checkSchema(ctx context.Context, migrator gogios.Migrator, auto bool) error {
+	if auto {
+		_, err := migrator.Migrate(ctx)
+		return err
+	}
+
+	statuses, err := migrator.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.Unknown {
+			return fmt.Errorf("schema version %d is newer than this version of gogios", status.Version)
+		}
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d schema migrations are pending, run `gogios db migrate`", pending)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"bytes"
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -45,3 +46,23 @@ func (d *Duration) UnmarshalTOML(b []byte) error {
 
 	return nil
 }
+
+// UnmarshalJSON parses a duration from the check list, either a string such
+// as "1.5s" or a number of seconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	if uq, err := strconv.Unquote(string(b)); err == nil {
+		d.Duration, err = time.ParseDuration(uq)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", uq)
+		}
+		return nil
+	}
+
+	seconds, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	d.Duration = time.Duration(seconds * float64(time.Second))
+
+	return nil
+}
@@ -0,0 +1,230 @@
+// Package metrics keeps track of how gogios itself is doing and renders that,
+// along with the state of every check, in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+// summary is a running count and total, which Prometheus turns into rates
+// and averages
+type summary struct {
+	count uint64
+	sum   float64
+}
+
+type operation struct {
+	database string
+	op       string
+}
+
+var (
+	mu sync.Mutex
+
+	lastRound    float64
+	roundSeconds summary
+
+	notifierErrors = map[string]uint64{}
+	outputErrors   = map[string]uint64{}
+	databaseTimes  = map[operation]*summary{}
+
+	checksInFlight atomic.Int64
+	checksQueued   atomic.Int64
+	checksSkipped  atomic.Uint64
+)
+
+// ObserveRound records how long a whole round of checks took
+func ObserveRound(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lastRound = d.Seconds()
+	roundSeconds.count++
+	roundSeconds.sum += d.Seconds()
+}
+
+// CheckStarted and CheckFinished track how many checks are running right now
+func CheckStarted() {
+	checksInFlight.Add(1)
+}
+
+func CheckFinished() {
+	checksInFlight.Add(-1)
+}
+
+// CheckQueued and CheckDequeued track how many checks are waiting for a slot
+func CheckQueued() {
+	checksQueued.Add(1)
+}
+
+func CheckDequeued() {
+	checksQueued.Add(-1)
+}
+
+// CheckSkipped counts a check that was not run because it was still running
+// from an earlier round
+func CheckSkipped() {
+	checksSkipped.Add(1)
+}
+
+// NotifierError counts a failed notification
+func NotifierError(notifier string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	notifierErrors[notifier]++
+}
+
+// OutputError counts a failed write to an output
+func OutputError(output string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	outputErrors[output]++
+}
+
+// ObserveDatabase records how long a read or write against a database took
+func ObserveDatabase(database, op string, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := operation{database: database, op: op}
+	s, ok := databaseTimes[key]
+	if !ok {
+		s = &summary{}
+		databaseTimes[key] = s
+	}
+	s.count++
+	s.sum += d.Seconds()
+}
+
+// Write renders the checks and the internal metrics in the Prometheus text
+// exposition format. Checks may be nil if they could not be read
+func Write(w io.Writer, checks []gogios.Check) error {
+	b := bufio.NewWriter(w)
+
+	if len(checks) > 0 {
+		writeChecks(b, checks)
+	}
+
+	mu.Lock()
+	writeHeader(b, "gogios_last_round_duration_seconds", "gauge", "How long the most recent round of checks took")
+	fmt.Fprintf(b, "gogios_last_round_duration_seconds %s\n", formatFloat(lastRound))
+	writeHeader(b, "gogios_round_duration_seconds", "summary", "How long rounds of checks take")
+	fmt.Fprintf(b, "gogios_round_duration_seconds_sum %s\n", formatFloat(roundSeconds.sum))
+	fmt.Fprintf(b, "gogios_round_duration_seconds_count %d\n", roundSeconds.count)
+
+	writeCounters(b, "gogios_notifier_errors_total", "Notifications that failed to send", "notifier", notifierErrors)
+	writeCounters(b, "gogios_output_errors_total", "Writes to outputs that failed", "output", outputErrors)
+
+	if len(databaseTimes) > 0 {
+		keys := make([]operation, 0, len(databaseTimes))
+		for key := range databaseTimes {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].database != keys[j].database {
+				return keys[i].database < keys[j].database
+			}
+			return keys[i].op < keys[j].op
+		})
+
+		writeHeader(b, "gogios_database_duration_seconds", "summary", "How long reads and writes against each database take")
+		for _, key := range keys {
+			labels := fmt.Sprintf(`{database="%s",operation="%s"}`, escape(key.database), escape(key.op))
+			fmt.Fprintf(b, "gogios_database_duration_seconds_sum%s %s\n", labels, formatFloat(databaseTimes[key].sum))
+			fmt.Fprintf(b, "gogios_database_duration_seconds_count%s %d\n", labels, databaseTimes[key].count)
+		}
+	}
+	mu.Unlock()
+
+	writeHeader(b, "gogios_checks_in_flight", "gauge", "Checks that are running right now")
+	fmt.Fprintf(b, "gogios_checks_in_flight %d\n", checksInFlight.Load())
+	writeHeader(b, "gogios_checks_queued", "gauge", "Checks that are waiting for a free slot")
+	fmt.Fprintf(b, "gogios_checks_queued %d\n", checksQueued.Load())
+	writeHeader(b, "gogios_checks_skipped_total", "counter", "Checks that were skipped because they were still running from an earlier round")
+	fmt.Fprintf(b, "gogios_checks_skipped_total %d\n", checksSkipped.Load())
+	writeHeader(b, "gogios_goroutines", "gauge", "Goroutines that currently exist")
+	fmt.Fprintf(b, "gogios_goroutines %d\n", runtime.NumGoroutine())
+
+	return b.Flush()
+}
+
+func writeChecks(b *bufio.Writer, checks []gogios.Check) {
+	gauges := []struct {
+		name  string
+		help  string
+		value func(gogios.Check) string
+	}{
+		{"gogios_check_status", "Status of the most recent run: 0 success, 1 warning, 2 failed, 3 timed out", func(c gogios.Check) string {
+			return strconv.Itoa(gogios.StatusCode(c.Status))
+		}},
+		{"gogios_check_last_run_timestamp_seconds", "When the most recent run finished", func(c gogios.Check) string {
+			return formatFloat(float64(c.Asof.UnixNano()) / 1e9)
+		}},
+		{"gogios_check_duration_seconds", "How long the most recent run took", func(c gogios.Check) string {
+			return formatFloat(c.Duration.Seconds())
+		}},
+		{"gogios_check_good_count", "Number of runs that succeeded", func(c gogios.Check) string {
+			return strconv.Itoa(c.GoodCount)
+		}},
+		{"gogios_check_total_count", "Number of times the check has run", func(c gogios.Check) string {
+			return strconv.Itoa(c.TotalCount)
+		}},
+	}
+
+	labels := make([]string, len(checks))
+	for i, check := range checks {
+		labels[i] = fmt.Sprintf(`{title="%s",tags="%s"}`, escape(check.Title), escape(strings.Join(check.TagList(), ",")))
+	}
+
+	for _, gauge := range gauges {
+		writeHeader(b, gauge.name, "gauge", gauge.help)
+		for i, check := range checks {
+			fmt.Fprintf(b, "%s%s %s\n", gauge.name, labels[i], gauge.value(check))
+		}
+	}
+}
+
+func writeCounters(b *bufio.Writer, name, help, label string, counters map[string]uint64) {
+	if len(counters) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writeHeader(b, name, "counter", help)
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %d\n", name, label, escape(key), counters[key])
+	}
+}
+
+func writeHeader(b *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape makes a string safe to use as a label value
+func escape(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+func TestWrite(t *testing.T) {
+	ObserveRound(2 * time.Second)
+	NotifierError("slack (ops)")
+	NotifierError("slack (ops)")
+	ObserveDatabase("sqlite", "read", 250*time.Millisecond)
+	CheckStarted()
+	defer CheckFinished()
+
+	checks := []gogios.Check{
+		{Title: `Disk "root"`, Status: gogios.StatusFailed, GoodCount: 4, TotalCount: 5, Asof: time.Unix(1600000000, 500000000), Duration: 1500 * time.Millisecond},
+		{Title: "Web", Status: gogios.StatusSuccess, Tags: " web, production,,"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, checks); err != nil {
+		t.Fatalf("Write failed, got error: %s", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE gogios_check_status gauge\n",
+		`gogios_check_status{title="Disk \"root\"",tags=""} 2` + "\n",
+		`gogios_check_last_run_timestamp_seconds{title="Disk \"root\"",tags=""} 1.6000000005e+09` + "\n",
+		`gogios_check_duration_seconds{title="Disk \"root\"",tags=""} 1.5` + "\n",
+		`gogios_check_good_count{title="Disk \"root\"",tags=""} 4` + "\n",
+		`gogios_check_total_count{title="Disk \"root\"",tags=""} 5` + "\n",
+		`gogios_check_status{title="Web",tags="web,production"} 0` + "\n",
+		"gogios_last_round_duration_seconds 2\n",
+		`gogios_notifier_errors_total{notifier="slack (ops)"} 2` + "\n",
+		`gogios_database_duration_seconds_count{database="sqlite",operation="read"} 1` + "\n",
+		"gogios_checks_in_flight 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics are missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteWithoutChecks(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, nil); err != nil {
+		t.Fatalf("Write failed, got error: %s", err)
+	}
+
+	if strings.Contains(buf.String(), "gogios_check_status") {
+		t.Errorf("No check metrics should be written without checks")
+	}
+	if !strings.Contains(buf.String(), "gogios_goroutines ") {
+		t.Errorf("Internal metrics should always be written")
+	}
+}
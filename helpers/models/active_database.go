@@ -1,15 +1,26 @@
 package models
 
-import "github.com/bkasin/gogios"
+import (
+	"context"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
 
 type ActiveDatabase struct {
 	Database gogios.Database
 	Config   *DatabaseConfig
+
+	deadline deadline
 }
 
+// DatabaseConfig holds the per-instance options that every database accepts
 type DatabaseConfig struct {
 	Name  string
 	Alias string
+
+	// Timeout bounds how long a single write may take
+	Timeout time.Duration
 }
 
 func NewActiveDatabase(database gogios.Database, config *DatabaseConfig) *ActiveDatabase {
@@ -18,3 +29,51 @@ func NewActiveDatabase(database gogios.Database, config *DatabaseConfig) *Active
 		Config:   config,
 	}
 }
+
+// LogName returns the name of the database along with its alias, if it has one
+func (d *ActiveDatabase) LogName() string {
+	if d.Config.Alias == "" {
+		return d.Config.Name
+	}
+
+	return d.Config.Name + " (" + d.Config.Alias + ")"
+}
+
+// AddCheck writes the check to the database, giving up once the configured
+// timeout has passed
+func (d *ActiveDatabase) AddCheck(ctx context.Context, check gogios.Check, output gogios.CheckOutput) error {
+	return d.withTimeout(ctx, func(ctx context.Context) error {
+		return d.Database.AddCheck(ctx, check, output)
+	})
+}
+
+// DeleteCheck removes the check from the database, giving up once the
+// configured timeout has passed
+func (d *ActiveDatabase) DeleteCheck(ctx context.Context, check gogios.Check, field string) error {
+	return d.withTimeout(ctx, func(ctx context.Context) error {
+		return d.Database.DeleteCheck(ctx, check, field)
+	})
+}
+
+// AddUser adds the user to the database, giving up once the configured
+// timeout has passed
+func (d *ActiveDatabase) AddUser(ctx context.Context, user gogios.User) error {
+	return d.withTimeout(ctx, func(ctx context.Context) error {
+		return d.Database.AddUser(ctx, user)
+	})
+}
+
+// DeleteUser removes the user from the database, giving up once the
+// configured timeout has passed
+func (d *ActiveDatabase) DeleteUser(ctx context.Context, user gogios.User) error {
+	return d.withTimeout(ctx, func(ctx context.Context) error {
+		return d.Database.DeleteUser(ctx, user)
+	})
+}
+
+// withTimeout runs f with a context that expires after the configured timeout.
+// Backends may not notice the context mid-statement, in which case the write
+// is abandoned but may still be applied later
+func (d *ActiveDatabase) withTimeout(ctx context.Context, f func(context.Context) error) error {
+	return d.deadline.run(ctx, "database "+d.LogName(), d.Config.Timeout, f)
+}
@@ -1,15 +1,31 @@
 package models
 
-import "github.com/bkasin/gogios"
+import (
+	"context"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
 
 type ActiveNotifier struct {
 	Notifier gogios.Notifier
 	Config   *NotifierConfig
+
+	deadline deadline
 }
 
+// NotifierConfig holds the per-instance options that every notifier accepts
 type NotifierConfig struct {
 	Name  string
 	Alias string
+
+	// Filters are glob patterns matched against check titles. When set, only
+	// matching checks are sent through the notifier.
+	Filters []string
+	// Timeout bounds how long a single Notify call may take
+	Timeout time.Duration
+	// MinSeverity is the lowest severity that triggers a notification
+	MinSeverity int
 }
 
 func NewActiveNotifier(notifier gogios.Notifier, config *NotifierConfig) *ActiveNotifier {
@@ -18,3 +34,32 @@ func NewActiveNotifier(notifier gogios.Notifier, config *NotifierConfig) *Active
 		Config:   config,
 	}
 }
+
+// LogName returns the name of the notifier along with its alias, if it has one
+func (n *ActiveNotifier) LogName() string {
+	if n.Config.Alias == "" {
+		return n.Config.Name
+	}
+
+	return n.Config.Name + " (" + n.Config.Alias + ")"
+}
+
+// Accepts reports whether a check changing from prevStatus to status should be
+// routed through this notifier. A change is sent when either side of it is at
+// or above MinSeverity, so recoveries are announced along with the problem.
+func (n *ActiveNotifier) Accepts(check, status, prevStatus string) bool {
+	if !matchFilters(n.Config.Filters, check) {
+		return false
+	}
+
+	return gogios.Severity(status) >= n.Config.MinSeverity || gogios.Severity(prevStatus) >= n.Config.MinSeverity
+}
+
+// Notify sends through the notifier, giving up once the configured timeout
+// has passed. Notifiers cannot be cancelled, so one that is slow may still
+// deliver the message after that
+func (n *ActiveNotifier) Notify(check, asof, output, status string) error {
+	return n.deadline.run(context.Background(), "notifier "+n.LogName(), n.Config.Timeout, func(context.Context) error {
+		return n.Notifier.Notify(check, asof, output, status)
+	})
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+type ActiveOutput struct {
+	Output gogios.Output
+	Config *OutputConfig
+
+	deadline deadline
+}
+
+// OutputConfig holds the per-instance options that every output accepts
+type OutputConfig struct {
+	Name  string
+	Alias string
+
+	// Filters are glob patterns matched against check titles. When set, only
+	// matching checks are written to the output.
+	Filters []string
+	// Timeout bounds how long a single Write may take
+	Timeout time.Duration
+}
+
+func NewActiveOutput(output gogios.Output, config *OutputConfig) *ActiveOutput {
+	return &ActiveOutput{
+		Output: output,
+		Config: config,
+	}
+}
+
+// LogName returns the name of the output along with its alias, if it has one
+func (o *ActiveOutput) LogName() string {
+	if o.Config.Alias == "" {
+		return o.Config.Name
+	}
+
+	return o.Config.Name + " (" + o.Config.Alias + ")"
+}
+
+// Write sends the checks that pass the filters to the output, giving up once
+// the configured timeout has passed. An output that ignores the context keeps
+// writing in the background after that
+func (o *ActiveOutput) Write(ctx context.Context, checks []gogios.Check) error {
+	var selected []gogios.Check
+	for _, check := range checks {
+		if matchFilters(o.Config.Filters, check.Title) {
+			selected = append(selected, check)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	return o.deadline.run(ctx, "output "+o.LogName(), o.Config.Timeout, func(ctx context.Context) error {
+		return o.Output.Write(ctx, selected)
+	})
+}
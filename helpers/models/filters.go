@@ -0,0 +1,19 @@
+package models
+
+import "path"
+
+// matchFilters reports whether a check title matches any of the glob
+// patterns. No patterns at all matches everything.
+func matchFilters(filters []string, check string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	for _, filter := range filters {
+		if ok, _ := path.Match(filter, check); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deadline runs the calls into one plugin under its configured timeout. It is
+// shared by the active databases, outputs and notifiers.
+//
+// f gets a context that is done once the timeout passes, and plugins are
+// expected to give up when it is. A call that does not is abandoned: the
+// caller gets an error, but f keeps running in the background and whatever it
+// was writing may still land. While any abandoned call is still running, new
+// calls fail straight away instead of starting more work, so a plugin that
+// hangs ties up a bounded number of goroutines rather than one per call.
+type deadline struct {
+	abandoned atomic.Int64
+}
+
+// run calls f with a context that expires after timeout, or directly if the
+// timeout is 0. name describes the plugin in errors
+func (d *deadline) run(ctx context.Context, name string, timeout time.Duration, f func(context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	if n := d.abandoned.Load(); n > 0 {
+		return fmt.Errorf("%s is still busy with %d earlier calls that timed out", name, n)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	var mu sync.Mutex
+	finished, abandoned := false, false
+	errChannel := make(chan error, 1)
+	go func() {
+		defer cancel()
+		err := f(ctx)
+
+		mu.Lock()
+		finished = true
+		if abandoned {
+			d.abandoned.Add(-1)
+		}
+		mu.Unlock()
+
+		errChannel <- err
+	}()
+
+	select {
+	case err := <-errChannel:
+		return err
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if finished {
+		// f returned as the timeout passed, so its result still counts
+		return <-errChannel
+	}
+	abandoned = true
+	d.abandoned.Add(1)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out after %s: %w", name, timeout, ctx.Err())
+	}
+	return fmt.Errorf("%s: %w", name, ctx.Err())
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	var d deadline
+	ctx := context.Background()
+	errBroken := errors.New("broken")
+
+	if err := d.run(ctx, "test", 0, func(context.Context) error { return errBroken }); err != errBroken {
+		t.Errorf("Without a timeout f should be called directly, got %v", err)
+	}
+	if err := d.run(ctx, "test", time.Second, func(context.Context) error { return errBroken }); err != errBroken {
+		t.Errorf("Expected the error from f, got %v", err)
+	}
+
+	// A plugin that watches the context stops at the deadline
+	err := d.run(ctx, "test", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to pass, got %v", err)
+	}
+	waitFor(t, func() bool { return d.abandoned.Load() == 0 })
+
+	// One that does not is abandoned, and blocks new calls until it returns
+	release := make(chan struct{})
+	err = d.run(ctx, "test", 10*time.Millisecond, func(context.Context) error {
+		<-release
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "test timed out after 10ms") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+
+	called := false
+	err = d.run(ctx, "test", time.Second, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Errorf("Calls should be refused while one that timed out is running, got %v", err)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return d.abandoned.Load() == 0 })
+	if err := d.run(ctx, "test", time.Second, func(context.Context) error { return nil }); err != nil {
+		t.Errorf("Calls should work again once the abandoned one returned, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting")
+}
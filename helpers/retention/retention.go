@@ -0,0 +1,275 @@
+// Package retention rolls old check history up into hourly and daily rows and
+// prunes whatever is past its retention period.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/helpers/models"
+	"github.com/google/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// batchSize is how many raw history rows are rolled up at a time
+const batchSize = 5000
+
+// Policy says how long history is kept at each resolution. A zero duration
+// keeps that resolution forever, and a zero Raw disables pruning entirely.
+type Policy struct {
+	Raw    time.Duration
+	Hourly time.Duration
+	Daily  time.Duration
+}
+
+// Stats counts what a single Prune call did
+type Stats struct {
+	RawRolledUp    int
+	HourlyRolledUp int
+	DailyDeleted   int
+}
+
+// Enabled reports whether the policy will ever remove anything
+func (p Policy) Enabled() bool {
+	return p.Raw > 0
+}
+
+type rollupKey struct {
+	checkID uint
+	start   int64
+}
+
+// periodStart returns the beginning of the hour or day that t falls in, in UTC
+func periodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	if period == gogios.RollupDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	return t.Truncate(time.Hour)
+}
+
+// Rollup groups raw history into one rollup per check per period
+func Rollup(history []gogios.CheckHistory, period string) []gogios.CheckHistoryRollup {
+	rollups := map[rollupKey]*gogios.CheckHistoryRollup{}
+	durations := map[rollupKey]time.Duration{}
+
+	for _, h := range history {
+		if h.CheckID == nil || h.Asof == nil {
+			continue
+		}
+
+		start := periodStart(*h.Asof, period)
+		key := rollupKey{checkID: *h.CheckID, start: start.Unix()}
+
+		r, ok := rollups[key]
+		if !ok {
+			r = &gogios.CheckHistoryRollup{CheckID: *h.CheckID, Period: period, Start: start}
+			rollups[key] = r
+		}
+
+		if h.Status != nil && gogios.Severity(*h.Status) < gogios.SeverityCritical {
+			r.Up++
+		} else {
+			r.Down++
+		}
+		durations[key] += h.Duration
+	}
+
+	var result []gogios.CheckHistoryRollup
+	for key, r := range rollups {
+		r.AvgDuration = durations[key] / time.Duration(r.Up+r.Down)
+		result = append(result, *r)
+	}
+	sortRollups(result)
+
+	return result
+}
+
+// Combine rolls finer grained rollups up into a coarser period, weighting the
+// average durations by the number of runs in each
+func Combine(rollups []gogios.CheckHistoryRollup, period string) []gogios.CheckHistoryRollup {
+	var result []gogios.CheckHistoryRollup
+	for _, r := range rollups {
+		r.Model = gorm.Model{}
+		r.Period = period
+		r.Start = periodStart(r.Start, period)
+		result = Merge(result, []gogios.CheckHistoryRollup{r})
+	}
+
+	return result
+}
+
+// Merge adds fresh rollups into existing ones that cover the same check and
+// period start. Existing rollups keep their IDs so saving them updates the
+// stored rows.
+func Merge(existing, fresh []gogios.CheckHistoryRollup) []gogios.CheckHistoryRollup {
+	index := map[rollupKey]int{}
+	merged := append([]gogios.CheckHistoryRollup{}, existing...)
+	for i, r := range merged {
+		index[rollupKey{checkID: r.CheckID, start: r.Start.Unix()}] = i
+	}
+
+	for _, r := range fresh {
+		key := rollupKey{checkID: r.CheckID, start: r.Start.Unix()}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+
+		m := &merged[i]
+		runs := m.Up + m.Down + r.Up + r.Down
+		if runs > 0 {
+			m.AvgDuration = (m.AvgDuration*time.Duration(m.Up+m.Down) + r.AvgDuration*time.Duration(r.Up+r.Down)) / time.Duration(runs)
+		}
+		m.Up += r.Up
+		m.Down += r.Down
+	}
+	sortRollups(merged)
+
+	return merged
+}
+
+func sortRollups(rollups []gogios.CheckHistoryRollup) {
+	sort.SliceStable(rollups, func(i, j int) bool {
+		if !rollups[i].Start.Equal(rollups[j].Start) {
+			return rollups[i].Start.Before(rollups[j].Start)
+		}
+		return rollups[i].CheckID < rollups[j].CheckID
+	})
+}
+
+// Prune applies the policy to a database once. Raw rows older than
+// policy.Raw become hourly rollups, hourly rollups older than policy.Hourly
+// become daily rollups, and daily rollups older than policy.Daily are deleted.
+// Each batch of rollups is saved in the same transaction that deletes the rows
+// it replaces, so an interrupted prune neither loses runs nor counts them
+// twice when it is run again.
+func Prune(ctx context.Context, db gogios.Database, policy Policy, now time.Time) (Stats, error) {
+	var stats Stats
+	if !policy.Enabled() {
+		return stats, nil
+	}
+
+	// Only whole hours are rolled up so a rollup never has to be split later
+	rawCutoff := periodStart(now.Add(-policy.Raw), gogios.RollupHour)
+	for {
+		history, err := db.GetHistoryBefore(ctx, rawCutoff, batchSize)
+		if err != nil {
+			return stats, fmt.Errorf("reading history: %w", err)
+		}
+		if len(history) == 0 {
+			break
+		}
+
+		rollups, err := addRollups(ctx, db, gogios.RollupHour, Rollup(history, gogios.RollupHour))
+		if err != nil {
+			return stats, err
+		}
+
+		ids := make([]uint, 0, len(history))
+		for _, h := range history {
+			ids = append(ids, h.ID)
+		}
+		if err := db.ReplaceHistoryWithRollups(ctx, rollups, ids, nil); err != nil {
+			return stats, fmt.Errorf("replacing history with hourly rollups: %w", err)
+		}
+
+		stats.RawRolledUp += len(history)
+		if len(history) < batchSize {
+			break
+		}
+	}
+
+	if policy.Hourly > 0 {
+		hourlyCutoff := periodStart(now.Add(-policy.Hourly), gogios.RollupDay)
+		hourly, err := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, hourlyCutoff)
+		if err != nil {
+			return stats, fmt.Errorf("reading hourly rollups: %w", err)
+		}
+
+		if len(hourly) > 0 {
+			daily, err := addRollups(ctx, db, gogios.RollupDay, Combine(hourly, gogios.RollupDay))
+			if err != nil {
+				return stats, err
+			}
+			if err := db.ReplaceHistoryWithRollups(ctx, daily, nil, rollupIDs(hourly)); err != nil {
+				return stats, fmt.Errorf("replacing hourly rollups with daily ones: %w", err)
+			}
+			stats.HourlyRolledUp = len(hourly)
+		}
+	}
+
+	if policy.Daily > 0 {
+		dailyCutoff := periodStart(now.Add(-policy.Daily), gogios.RollupDay)
+		daily, err := db.GetRollups(ctx, gogios.RollupDay, time.Time{}, dailyCutoff)
+		if err != nil {
+			return stats, fmt.Errorf("reading daily rollups: %w", err)
+		}
+		if err := db.DeleteRollups(ctx, rollupIDs(daily)); err != nil {
+			return stats, fmt.Errorf("deleting daily rollups: %w", err)
+		}
+		stats.DailyDeleted = len(daily)
+	}
+
+	return stats, nil
+}
+
+// addRollups merges fresh rollups into any that are already stored for the
+// same periods, and returns the result for the caller to save
+func addRollups(ctx context.Context, db gogios.Database, period string, fresh []gogios.CheckHistoryRollup) ([]gogios.CheckHistoryRollup, error) {
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+
+	from := fresh[0].Start
+	to := fresh[len(fresh)-1].Start.Add(time.Second)
+	existing, err := db.GetRollups(ctx, period, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s rollups: %w", period, err)
+	}
+
+	return Merge(existing, fresh), nil
+}
+
+func rollupIDs(rollups []gogios.CheckHistoryRollup) []uint {
+	ids := make([]uint, 0, len(rollups))
+	for _, r := range rollups {
+		ids = append(ids, r.ID)
+	}
+
+	return ids
+}
+
+// Run prunes every database once per interval until the context is done
+func Run(ctx context.Context, databases []*models.ActiveDatabase, policy Policy, interval time.Duration, logger *logger.Logger) {
+	if !policy.Enabled() || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, database := range databases {
+			stats, err := Prune(ctx, database.Database, policy, time.Now())
+			if err != nil {
+				logger.Errorf("Pruning history in %s failed: %s", database.LogName(), err.Error())
+				continue
+			}
+			logger.Infof("Pruned history in %s: %d raw rows rolled up, %d hourly rollups rolled up, %d daily rollups deleted",
+				database.LogName(), stats.RawRolledUp, stats.HourlyRolledUp, stats.DailyDeleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/databases/sqlite"
+)
+
+func historyAt(id uint, asof time.Time, status string, duration time.Duration) gogios.CheckHistory {
+	return gogios.CheckHistory{CheckID: &id, Asof: &asof, Status: &status, Duration: duration}
+}
+
+func TestRollup(t *testing.T) {
+	hour := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	history := []gogios.CheckHistory{
+		historyAt(1, hour.Add(5*time.Minute), gogios.StatusSuccess, 2*time.Second),
+		historyAt(1, hour.Add(10*time.Minute), gogios.StatusFailed, 4*time.Second),
+		historyAt(1, hour.Add(70*time.Minute), gogios.StatusTimedOut, 6*time.Second),
+		historyAt(2, hour.Add(15*time.Minute), gogios.StatusWarning, time.Second),
+	}
+
+	rollups := Rollup(history, gogios.RollupHour)
+	if len(rollups) != 3 {
+		t.Fatalf("Expected 3 rollups, got %d", len(rollups))
+	}
+
+	first := rollups[0]
+	if first.CheckID != 1 || !first.Start.Equal(hour) || first.Up != 1 || first.Down != 1 || first.AvgDuration != 3*time.Second {
+		t.Errorf("Unexpected first rollup: %+v", first)
+	}
+	if rollups[1].CheckID != 2 || rollups[1].Up != 1 {
+		t.Errorf("Warnings should count as up, got: %+v", rollups[1])
+	}
+	if !rollups[2].Start.Equal(hour.Add(time.Hour)) || rollups[2].Down != 1 {
+		t.Errorf("Unexpected last rollup: %+v", rollups[2])
+	}
+
+	daily := Combine(rollups, gogios.RollupDay)
+	if len(daily) != 2 {
+		t.Fatalf("Expected 2 daily rollups, got %d", len(daily))
+	}
+	if daily[0].Up != 1 || daily[0].Down != 2 || daily[0].AvgDuration != 4*time.Second {
+		t.Errorf("Unexpected daily rollup: %+v", daily[0])
+	}
+}
+
+// flaky fails the first time it is asked to delete history, whether alone or
+// together with saving rollups
+type flaky struct {
+	*sqlite.Sqlite
+	failed bool
+}
+
+var errFlaky = errors.New("connection reset")
+
+func (f *flaky) fail() error {
+	if f.failed {
+		return nil
+	}
+	f.failed = true
+	return errFlaky
+}
+
+func (f *flaky) DeleteHistory(ctx context.Context, ids []uint) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Sqlite.DeleteHistory(ctx, ids)
+}
+
+func (f *flaky) ReplaceHistoryWithRollups(ctx context.Context, rollups []gogios.CheckHistoryRollup, historyIDs, rollupIDs []uint) error {
+	if len(historyIDs) > 0 {
+		if err := f.fail(); err != nil {
+			return err
+		}
+	}
+	return f.Sqlite.ReplaceHistoryWithRollups(ctx, rollups, historyIDs, rollupIDs)
+}
+
+// open returns a migrated database holding four runs of a check, two of them
+// 40 days before now
+func open(t *testing.T, now time.Time) *sqlite.Sqlite {
+	ctx := context.Background()
+	db := &sqlite.Sqlite{DBFile: filepath.Join(t.TempDir(), "gogios.db")}
+	if err := db.Init(); err != nil {
+		t.Fatalf("Could not open database, got error: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Could not migrate database, got error: %s", err)
+	}
+
+	for _, asof := range []time.Time{
+		now.Add(-40 * 24 * time.Hour),
+		now.Add(-40*24*time.Hour + time.Minute),
+		now.Add(-3 * 24 * time.Hour),
+		now.Add(-time.Hour),
+	} {
+		check := gogios.Check{Title: "ping", Status: gogios.StatusSuccess, Asof: asof, Duration: time.Second}
+		if prev, err := db.GetCheck(ctx, "ping", "title"); err == nil {
+			check.ID = prev.ID
+		}
+		if err := db.AddCheck(ctx, check, gogios.CheckOutput{Stdout: "ok"}); err != nil {
+			t.Fatalf("Could not add check, got error: %s", err)
+		}
+	}
+
+	return db
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 3, 10, 12, 30, 0, 0, time.UTC)
+	db := open(t, now)
+
+	policy := Policy{Raw: 2 * 24 * time.Hour, Hourly: 30 * 24 * time.Hour}
+	stats, err := Prune(ctx, db, policy, now)
+	if err != nil {
+		t.Fatalf("Prune failed, got error: %s", err)
+	}
+	if stats.RawRolledUp != 3 || stats.HourlyRolledUp != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	check, _ := db.GetCheck(ctx, "ping", "title")
+	history, _ := db.GetCheckHistory(ctx, check, 10)
+	if len(history) != 1 {
+		t.Errorf("Expected 1 raw row to be kept, got %d", len(history))
+	}
+
+	hourly, _ := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, now)
+	daily, _ := db.GetRollups(ctx, gogios.RollupDay, time.Time{}, now)
+	if len(hourly) != 1 || hourly[0].Up != 1 {
+		t.Errorf("Expected 1 hourly rollup, got %+v", hourly)
+	}
+	if len(daily) != 1 || daily[0].Up != 2 {
+		t.Errorf("Expected 1 daily rollup covering 2 runs, got %+v", daily)
+	}
+
+	// A second run has nothing left to do
+	stats, err = Prune(ctx, db, policy, now)
+	if err != nil || stats != (Stats{}) {
+		t.Errorf("Expected an idle second prune, got %+v and %v", stats, err)
+	}
+}
+
+// TestPruneRetry fails the first prune part way through and checks that
+// running it again counts every run once
+func TestPruneRetry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 3, 10, 12, 30, 0, 0, time.UTC)
+	db := &flaky{Sqlite: open(t, now)}
+
+	policy := Policy{Raw: 2 * 24 * time.Hour}
+	if _, err := Prune(ctx, db, policy, now); !errors.Is(err, errFlaky) {
+		t.Fatalf("Expected the first prune to fail, got %v", err)
+	}
+	stats, err := Prune(ctx, db, policy, now)
+	if err != nil || stats.RawRolledUp != 3 {
+		t.Fatalf("Expected the second prune to roll up 3 rows, got %+v and %v", stats, err)
+	}
+
+	hourly, _ := db.GetRollups(ctx, gogios.RollupHour, time.Time{}, now)
+	runs := 0
+	for _, rollup := range hourly {
+		runs += rollup.Up + rollup.Down
+	}
+	if len(hourly) != 2 || runs != 3 {
+		t.Errorf("Expected 2 hourly rollups covering 3 runs, got %+v", hourly)
+	}
+}
@@ -0,0 +1,91 @@
+// Package scheduler limits how many checks run at once and makes sure the
+// same check never runs twice at the same time.
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/bkasin/gogios/helpers/metrics"
+)
+
+// Pool runs checks on a limited number of slots. Checks that are submitted
+// while every slot is taken wait in a queue until one frees up
+type Pool struct {
+	slots chan struct{} // nil when there is no limit
+
+	mu      sync.Mutex
+	active  map[string]bool // Checks that are queued or running, by title
+	queued  int
+	running int
+}
+
+// New returns a pool that runs at most limit checks at once. A limit of 0 or
+// less means there is no limit
+func New(limit int) *Pool {
+	p := &Pool{active: map[string]bool{}}
+	if limit > 0 {
+		p.slots = make(chan struct{}, limit)
+	}
+
+	return p
+}
+
+// Submit queues f to run as soon as a slot is free. If the check with this
+// title is still queued or running, f is not run and false is returned
+func (p *Pool) Submit(title string, f func()) bool {
+	p.mu.Lock()
+	if p.active[title] {
+		p.mu.Unlock()
+		metrics.CheckSkipped()
+		return false
+	}
+	p.active[title] = true
+	p.queued++
+	p.mu.Unlock()
+	metrics.CheckQueued()
+
+	go func() {
+		if p.slots != nil {
+			p.slots <- struct{}{}
+		}
+
+		p.mu.Lock()
+		p.queued--
+		p.running++
+		p.mu.Unlock()
+		metrics.CheckDequeued()
+		metrics.CheckStarted()
+
+		defer func() {
+			p.mu.Lock()
+			p.running--
+			delete(p.active, title)
+			p.mu.Unlock()
+			metrics.CheckFinished()
+
+			if p.slots != nil {
+				<-p.slots
+			}
+		}()
+
+		f()
+	}()
+
+	return true
+}
+
+// Queued returns how many checks are waiting for a slot
+func (p *Pool) Queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.queued
+}
+
+// Running returns how many checks hold a slot right now
+func (p *Pool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.running
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	p := New(2)
+
+	var running, most atomic.Int64
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		ok := p.Submit("check "+strconv.Itoa(i), func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				m := most.Load()
+				if n <= m || most.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		})
+		if !ok {
+			t.Fatalf("Check %d should have been queued", i)
+		}
+	}
+
+	waitFor(t, func() bool { return p.Running() == 2 && p.Queued() == 3 })
+
+	close(release)
+	wg.Wait()
+
+	if most.Load() != 2 {
+		t.Errorf("Expected at most 2 checks at once, got %d", most.Load())
+	}
+	waitFor(t, func() bool { return p.Running() == 0 && p.Queued() == 0 })
+}
+
+func TestSkipRunning(t *testing.T) {
+	p := New(0)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	if !p.Submit("ping", func() {
+		<-release
+		close(done)
+	}) {
+		t.Fatal("The first run should have been queued")
+	}
+
+	if p.Submit("ping", func() {}) {
+		t.Error("A check that is still running should be skipped")
+	}
+
+	close(release)
+	<-done
+	waitFor(t, func() bool { return p.Submit("ping", func() {}) })
+}
+
+// waitFor polls cond until it is true or a few seconds have passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the pool")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
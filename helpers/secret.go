@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveSecret turns a secret reference into its value, so that passwords
+// and keys do not have to be written into the check list. "env:NAME" reads
+// an environment variable and "file:/path" reads a file, without its final
+// newline. Anything else is used as the value itself
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	default:
+		return ref, nil
+	}
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("GOGIOS_TEST_SECRET", "from env")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from file\n"), 0600); err != nil {
+		t.Fatalf("Could not write the secret, got error: %s", err)
+	}
+
+	tests := map[string]string{
+		"plain":                  "plain",
+		"env:GOGIOS_TEST_SECRET": "from env",
+		"file:" + path:           "from file",
+	}
+	for ref, want := range tests {
+		got, err := ResolveSecret(ref)
+		if err != nil || got != want {
+			t.Errorf("ResolveSecret(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+
+	if _, err := ResolveSecret("env:GOGIOS_TEST_UNSET"); err == nil {
+		t.Error("An unset environment variable should be an error")
+	}
+	if _, err := ResolveSecret("file:" + path + ".missing"); err == nil {
+		t.Error("A missing file should be an error")
+	}
+}
@@ -1,8 +1,6 @@
 package slack
 
 import (
-	"fmt"
-
 	"github.com/bkasin/gogios"
 	"github.com/bkasin/gogios/helpers"
 	"github.com/bkasin/gogios/notifiers"
@@ -52,11 +50,10 @@ func (s *Slack) Notify(check, time, output, status string) error {
 		},
 	}
 
-	channelID, timestamp, err := api.PostMessage(s.Channel, slack.MsgOptionText(check+" Status changed to "+status+" as of:\n"+time+"\n\nOutput of check was:", false), slack.MsgOptionAttachments(attachment))
+	_, _, err := api.PostMessage(s.Channel, slack.MsgOptionText(check+" Status changed to "+status+" as of:\n"+time+"\n\nOutput of check was:", false), slack.MsgOptionAttachments(attachment))
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Message successfully sent to channel %s at %s", channelID, timestamp)
 
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -55,6 +56,8 @@ func (t *Telegram) Description() string {
 
 func (t *Telegram) Notify(check, time, output, status string) error {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
 
 	// Shorten the message if it too long for a URL
 	message := ""
@@ -81,7 +84,10 @@ func (t *Telegram) Notify(check, time, output, status string) error {
 		u := "https://api.telegram.org/bot" + t.API + "/sendMessage?chat_id=" + c + "&text=" + message
 		addr, err := url.Parse(u)
 		if err != nil {
-			fmt.Println(fmt.Errorf("Telegram: Unable to parse address:\n'%s'\n\n%s", u, err))
+			// The address holds the API key, so it is left out of the error
+			mu.Lock()
+			errs = append(errs, "unable to build the address for chat "+c)
+			mu.Unlock()
 			continue
 		}
 
@@ -91,15 +97,27 @@ func (t *Telegram) Notify(check, time, output, status string) error {
 
 			resp, err := t.client.Get(addr.String())
 			if err != nil {
-				fmt.Println(err.Error())
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
 				return
 			}
+			resp.Body.Close()
 
-			fmt.Printf("Telegram message posted: %s", resp.Status)
+			if resp.StatusCode != http.StatusOK {
+				mu.Lock()
+				errs = append(errs, "sendMessage returned "+resp.Status)
+				mu.Unlock()
+				return
+			}
 		}(addr)
 	}
 
 	wg.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("Telegram: %s", strings.Join(errs, "; "))
+	}
+
 	return nil
 }
 
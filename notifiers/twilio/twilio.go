@@ -1,7 +1,6 @@
 package twilio
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -94,22 +93,23 @@ func (t *Twilio) Notify(check, time, output, status string) error {
 	msgData.Set("Body", message)
 	msgDataReader := *strings.NewReader(msgData.Encode())
 
-	req, _ := http.NewRequest("POST", urlString, &msgDataReader)
+	req, err := http.NewRequest("POST", urlString, &msgDataReader)
+	if err != nil {
+		return err
+	}
 	req.SetBasicAuth(t.SID, t.Token)
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	// Make HTTP POST request and return message SID
-	resp, _ := t.client.Do(req)
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		var data map[string]interface{}
-		decoder := json.NewDecoder(resp.Body)
-		err := decoder.Decode(&data)
-		if err == nil {
-			fmt.Println("Twilio message posted. SID: ", data["sid"])
-		}
-	} else {
-		fmt.Println("Error sending Twilio message. Return was: ", resp.Status)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Twilio: error sending message, return was: %s", resp.Status)
 	}
 
 	return nil
@@ -0,0 +1,21 @@
+package gogios
+
+import "context"
+
+// Output is implemented by plugins that only receive check results, such as
+// time-series databases. Unlike a Database nothing is ever read back.
+type Output interface {
+	SampleConfig() string
+	SubConfig() string
+
+	Description() string
+
+	// Write sends the results of one round of checks
+	Write(ctx context.Context, checks []Check) error
+
+	// Init performs one time setup of the output and returns an error if the
+	// configuration is invalid.
+	Init() error
+	// Close releases any connections held by the output.
+	Close() error
+}
@@ -0,0 +1,6 @@
+package all
+
+import (
+	_ "github.com/bkasin/gogios/outputs/graphite"
+	_ "github.com/bkasin/gogios/outputs/influxdb"
+)
@@ -0,0 +1,169 @@
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/outputs"
+)
+
+// Graphite writes check results using the Graphite plaintext protocol
+type Graphite struct {
+	Address  string
+	Protocol string
+	Prefix   string
+}
+
+var sampleConfig = `
+  ## Address of the carbon plaintext listener
+  address = "127.0.0.1:2003"
+
+  ## Either tcp or udp
+  protocol = "tcp"
+
+  ## Every metric is named <prefix>.<check title>.<field>
+  prefix = "gogios"
+`
+
+var subConfig = `
+  ## Address of the carbon plaintext listener
+  address = "%s"
+
+  ## Either tcp or udp
+  protocol = "tcp"
+
+  ## Every metric is named <prefix>.<check title>.<field>
+  prefix = "gogios"
+`
+
+// SampleConfig returns the default config for Graphite
+func (g *Graphite) SampleConfig() string {
+	return sampleConfig
+}
+
+// SubConfig returns the default config ready for variable substitution
+func (g *Graphite) SubConfig() string {
+	return subConfig
+}
+
+// Description returns a brief explanation of the output
+func (g *Graphite) Description() string {
+	return "Write check results to Graphite using the plaintext protocol"
+}
+
+// Init checks the address and protocol
+func (g *Graphite) Init() error {
+	g.Protocol = strings.ToLower(g.Protocol)
+	if g.Protocol == "" {
+		g.Protocol = "tcp"
+	}
+	if g.Protocol != "tcp" && g.Protocol != "udp" {
+		return fmt.Errorf("protocol must be tcp or udp, not %q", g.Protocol)
+	}
+
+	if _, _, err := net.SplitHostPort(g.Address); err != nil {
+		return fmt.Errorf("invalid address %q: %s", g.Address, err)
+	}
+
+	g.Prefix = strings.Trim(g.Prefix, ".")
+
+	return nil
+}
+
+// Close does nothing, a new connection is made for every write
+func (g *Graphite) Close() error {
+	return nil
+}
+
+// Write sends every check's metrics over one connection
+func (g *Graphite) Write(ctx context.Context, checks []gogios.Check) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, g.Protocol, g.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	// UDP gets one packet per check so that a single packet can't grow too big
+	var buf bytes.Buffer
+	for _, check := range checks {
+		for _, line := range Lines(g.Prefix, check) {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+
+		if g.Protocol == "udp" {
+			if _, err := conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+
+	if buf.Len() > 0 {
+		_, err = conn.Write(buf.Bytes())
+	}
+
+	return err
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Sanitize turns a check title into a single Graphite path segment
+func Sanitize(title string) string {
+	return strings.Trim(unsafeChars.ReplaceAllString(title, "_"), "_")
+}
+
+// Lines formats a check as Graphite plaintext lines, one for each field and
+// one for each measurement in its perfdata
+func Lines(prefix string, check gogios.Check) []string {
+	path := Sanitize(check.Title)
+	if prefix != "" {
+		path = prefix + "." + path
+	}
+	timestamp := strconv.FormatInt(check.Asof.Unix(), 10)
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"status", strconv.Itoa(gogios.StatusCode(check.Status))},
+		{"duration", strconv.FormatFloat(check.Duration.Seconds(), 'f', -1, 64)},
+		{"good_count", strconv.Itoa(check.GoodCount)},
+		{"total_count", strconv.Itoa(check.TotalCount)},
+	}
+
+	for _, perf := range check.Perfdata {
+		fields = append(fields, struct {
+			name  string
+			value string
+		}{"perf." + Sanitize(perf.Label), strconv.FormatFloat(perf.Value, 'f', -1, 64)})
+	}
+
+	lines := make([]string, 0, len(fields))
+	for _, field := range fields {
+		lines = append(lines, path+"."+field.name+" "+field.value+" "+timestamp)
+	}
+
+	return lines
+}
+
+func init() {
+	outputs.Add("graphite", func() gogios.Output {
+		return &Graphite{
+			Address:  "127.0.0.1:2003",
+			Protocol: "tcp",
+			Prefix:   "gogios",
+		}
+	})
+}
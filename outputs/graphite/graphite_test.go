@@ -0,0 +1,70 @@
+package graphite
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+func TestWriteTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	g := &Graphite{Address: listener.Addr().String(), Protocol: "TCP", Prefix: "gogios."}
+	if err := g.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+
+	checks := []gogios.Check{
+		{Title: "Web server (https)", Status: gogios.StatusFailed, GoodCount: 3, TotalCount: 4, Asof: time.Unix(1600000000, 0), Duration: 250 * time.Millisecond},
+	}
+	if err := g.Write(context.Background(), checks); err != nil {
+		t.Fatalf("Write failed, got error: %s", err)
+	}
+
+	want := "gogios.Web_server_https.status 2 1600000000\n" +
+		"gogios.Web_server_https.duration 0.25 1600000000\n" +
+		"gogios.Web_server_https.good_count 3 1600000000\n" +
+		"gogios.Web_server_https.total_count 4 1600000000\n"
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("Wrong metrics\nwant: %q\n got: %q", want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Listener never received the metrics")
+	}
+}
+
+func TestInit(t *testing.T) {
+	g := &Graphite{Address: "127.0.0.1:2003", Protocol: "http"}
+	if err := g.Init(); err == nil {
+		t.Errorf("An unknown protocol should be rejected")
+	}
+
+	g = &Graphite{Address: "127.0.0.1", Protocol: "udp"}
+	if err := g.Init(); err == nil {
+		t.Errorf("An address without a port should be rejected")
+	}
+}
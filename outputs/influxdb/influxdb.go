@@ -0,0 +1,260 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bkasin/gogios"
+	"github.com/bkasin/gogios/outputs"
+)
+
+// InfluxDB writes check results in line protocol, either to the HTTP write
+// API or as UDP packets
+type InfluxDB struct {
+	URL             string
+	Database        string
+	RetentionPolicy string `toml:"retention_policy"`
+	Username        string
+	Password        string
+	Token           string
+	Measurement     string
+	UDPPayload      int `toml:"udp_payload"`
+
+	client  *http.Client
+	writeTo *url.URL
+}
+
+var sampleConfig = `
+  ## HTTP(S) URL of the server, or udp://host:port to send UDP packets
+  url = "http://127.0.0.1:8086"
+
+  ## Database and optional retention policy to write to. InfluxDB 2 maps
+  ## these to a bucket
+  database = "gogios"
+  # retention_policy = ""
+
+  ## Basic authentication for InfluxDB 1, or an API token for InfluxDB 2
+  # username = ""
+  # password = ""
+  # token = ""
+
+  ## Name of the measurement every check is written to
+  measurement = "gogios"
+
+  ## Largest UDP packet to send, in bytes
+  # udp_payload = 512
+`
+
+var subConfig = `
+  ## HTTP(S) URL of the server, or udp://host:port to send UDP packets
+  url = "%s"
+
+  ## Database and optional retention policy to write to. InfluxDB 2 maps
+  ## these to a bucket
+  database = "%s"
+  # retention_policy = ""
+
+  ## Basic authentication for InfluxDB 1, or an API token for InfluxDB 2
+  # username = ""
+  # password = ""
+  # token = ""
+
+  ## Name of the measurement every check is written to
+  measurement = "gogios"
+
+  ## Largest UDP packet to send, in bytes
+  # udp_payload = 512
+`
+
+// SampleConfig returns the default config for InfluxDB
+func (i *InfluxDB) SampleConfig() string {
+	return sampleConfig
+}
+
+// SubConfig returns the default config ready for variable substitution
+func (i *InfluxDB) SubConfig() string {
+	return subConfig
+}
+
+// Description returns a brief explanation of the output
+func (i *InfluxDB) Description() string {
+	return "Write check results to InfluxDB in line protocol over HTTP or UDP"
+}
+
+// Init checks the URL and prepares the HTTP client
+func (i *InfluxDB) Init() error {
+	u, err := url.Parse(i.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %s", i.URL, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if i.Database == "" {
+			return fmt.Errorf("database is required when writing over HTTP")
+		}
+
+		query := url.Values{}
+		query.Set("db", i.Database)
+		if i.RetentionPolicy != "" {
+			query.Set("rp", i.RetentionPolicy)
+		}
+		query.Set("precision", "ns")
+
+		u = u.JoinPath("write")
+		u.RawQuery = query.Encode()
+		i.client = &http.Client{Timeout: 10 * time.Second}
+	case "udp":
+		if u.Host == "" {
+			return fmt.Errorf("url %q has no host", i.URL)
+		}
+		if i.UDPPayload <= 0 {
+			i.UDPPayload = 512
+		}
+	default:
+		return fmt.Errorf("url %q must use http, https or udp", i.URL)
+	}
+
+	i.writeTo = u
+
+	return nil
+}
+
+// Close does nothing, connections are not kept between writes
+func (i *InfluxDB) Close() error {
+	return nil
+}
+
+// Write sends the checks as one batch of points
+func (i *InfluxDB) Write(ctx context.Context, checks []gogios.Check) error {
+	if i.writeTo == nil {
+		return fmt.Errorf("output has not been initialized")
+	}
+
+	var lines []string
+	for _, check := range checks {
+		lines = append(lines, Line(i.Measurement, check))
+	}
+
+	if i.writeTo.Scheme == "udp" {
+		return i.writeUDP(ctx, lines)
+	}
+
+	return i.writeHTTP(ctx, lines)
+}
+
+func (i *InfluxDB) writeHTTP(ctx context.Context, lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.writeTo.String(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.Token)
+	} else if i.Username != "" {
+		req.SetBasicAuth(i.Username, i.Password)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// writeUDP packs as many lines into each packet as will fit. A line that is
+// too big on its own is sent by itself
+func (i *InfluxDB) writeUDP(ctx context.Context, lines []string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", i.writeTo.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > i.UDPPayload {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		packet.WriteString(line)
+		packet.WriteByte('\n')
+	}
+
+	if packet.Len() > 0 {
+		_, err = conn.Write(packet.Bytes())
+	}
+
+	return err
+}
+
+var (
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`, "\n", `\n`)
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+)
+
+// Line formats a check as a single line protocol point. The check title is a
+// tag, and the status is sent both as its name and as a 0-3 code. Perfdata
+// from built in check types is added as perf_<label> fields
+func Line(measurement string, check gogios.Check) string {
+	var b strings.Builder
+
+	b.WriteString(measurementEscaper.Replace(measurement))
+	b.WriteString(",check=")
+	b.WriteString(tagEscaper.Replace(check.Title))
+
+	b.WriteString(" status=")
+	b.WriteString(strconv.Itoa(gogios.StatusCode(check.Status)))
+	b.WriteString("i,state=")
+	b.WriteString(strconv.Quote(check.Status))
+	b.WriteString(",duration=")
+	b.WriteString(strconv.FormatFloat(check.Duration.Seconds(), 'f', -1, 64))
+	b.WriteString(",good_count=")
+	b.WriteString(strconv.Itoa(check.GoodCount))
+	b.WriteString("i,total_count=")
+	b.WriteString(strconv.Itoa(check.TotalCount))
+	b.WriteString("i")
+	for _, perf := range check.Perfdata {
+		b.WriteString(",perf_")
+		b.WriteString(tagEscaper.Replace(perf.Label))
+		b.WriteString("=")
+		b.WriteString(strconv.FormatFloat(perf.Value, 'f', -1, 64))
+	}
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(check.Asof.UnixNano(), 10))
+
+	return b.String()
+}
+
+func init() {
+	outputs.Add("influxdb", func() gogios.Output {
+		return &InfluxDB{
+			Measurement: "gogios",
+			UDPPayload:  512,
+		}
+	})
+}
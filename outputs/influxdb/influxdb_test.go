@@ -0,0 +1,112 @@
+package influxdb
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bkasin/gogios"
+)
+
+var checks = []gogios.Check{
+	{Title: "Web server", Status: gogios.StatusSuccess, GoodCount: 9, TotalCount: 10, Asof: time.Unix(1600000000, 5), Duration: 1500 * time.Millisecond},
+	{Title: "DNS, primary", Status: gogios.StatusTimedOut, GoodCount: 0, TotalCount: 2, Asof: time.Unix(1600000001, 0)},
+}
+
+func TestLine(t *testing.T) {
+	want := `gogios,check=Web\ server status=0i,state="Success",duration=1.5,good_count=9i,total_count=10i 1600000000000000005`
+	if got := Line("gogios", checks[0]); got != want {
+		t.Errorf("Line was wrong\nwant: %s\n got: %s", want, got)
+	}
+
+	want = `gogios,check=DNS\,\ primary status=3i,state="Timed Out",duration=0,good_count=0i,total_count=2i 1600000001000000000`
+	if got := Line("gogios", checks[1]); got != want {
+		t.Errorf("Line was wrong\nwant: %s\n got: %s", want, got)
+	}
+
+	withPerf := checks[0]
+	withPerf.Perfdata = []gogios.Perfdata{{Label: "time", Value: 0.25, Unit: "s"}, {Label: "size", Value: 512, Unit: "B"}}
+	want = `gogios,check=Web\ server status=0i,state="Success",duration=1.5,good_count=9i,total_count=10i,perf_time=0.25,perf_size=512 1600000000000000005`
+	if got := Line("gogios", withPerf); got != want {
+		t.Errorf("Line was wrong\nwant: %s\n got: %s", want, got)
+	}
+}
+
+func TestWriteHTTP(t *testing.T) {
+	var body, query, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body, query, auth = string(raw), r.URL.RawQuery, r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	i := &InfluxDB{URL: server.URL, Database: "checks", Measurement: "gogios", Token: "secret"}
+	if err := i.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+	if err := i.Write(context.Background(), checks); err != nil {
+		t.Fatalf("Write failed, got error: %s", err)
+	}
+
+	if query != "db=checks&precision=ns" {
+		t.Errorf("Wrong query string, got: %s", query)
+	}
+	if auth != "Token secret" {
+		t.Errorf("Token was not sent, got: %q", auth)
+	}
+	if lines := strings.Split(strings.TrimSpace(body), "\n"); len(lines) != 2 {
+		t.Errorf("Expected 2 lines, got: %q", body)
+	}
+}
+
+func TestWriteHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	i := &InfluxDB{URL: server.URL, Database: "checks", Measurement: "gogios"}
+	if err := i.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+
+	err := i.Write(context.Background(), checks)
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("Expected the server's error, got: %v", err)
+	}
+}
+
+func TestWriteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen, got error: %s", err)
+	}
+	defer conn.Close()
+
+	// Small enough that every line needs its own packet
+	i := &InfluxDB{URL: "udp://" + conn.LocalAddr().String(), Measurement: "gogios", UDPPayload: 64}
+	if err := i.Init(); err != nil {
+		t.Fatalf("Init failed, got error: %s", err)
+	}
+	if err := i.Write(context.Background(), checks); err != nil {
+		t.Fatalf("Write failed, got error: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	for _, check := range checks {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Could not read packet, got error: %s", err)
+		}
+		if want := Line("gogios", check) + "\n"; string(buf[:n]) != want {
+			t.Errorf("Wrong packet\nwant: %q\n got: %q", want, buf[:n])
+		}
+	}
+}
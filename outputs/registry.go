@@ -0,0 +1,11 @@
+package outputs
+
+import "github.com/bkasin/gogios"
+
+type Creator func() gogios.Output
+
+var Outputs = map[string]Creator{}
+
+func Add(name string, creator Creator) {
+	Outputs[name] = creator
+}
@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -37,7 +38,7 @@ func finalizeSetup(setupConfig string, setup Setup) error {
 		Password: setup.AdminPassword,
 	}
 
-	err = users.CreateUser(admin, config.Conf)
+	err = users.CreateUser(context.Background(), admin)
 	if err != nil {
 		return err
 	}
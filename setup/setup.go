@@ -31,6 +31,15 @@ type mysql struct {
 	Database string
 }
 
+type postgres struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+	SSLMode  string
+}
+
 type webOptions struct {
 	ListenIP   string
 	ListenPort string
@@ -56,6 +65,7 @@ type Setup struct {
 	DatabasePreference string
 	Sqlite             sqlite
 	Mysql              mysql
+	Postgres           postgres
 
 	Errors map[string]string
 }
@@ -96,6 +106,15 @@ func sendForm(w http.ResponseWriter, r *http.Request) {
 			Database: r.PostFormValue("mydb"),
 		}
 
+		setup.Postgres = postgres{
+			Host:     r.PostFormValue("pgurl"),
+			Port:     r.PostFormValue("pgport"),
+			User:     r.PostFormValue("pguser"),
+			Password: r.PostFormValue("pgpass"),
+			Database: r.PostFormValue("pgdb"),
+			SSLMode:  r.PostFormValue("pgsslmode"),
+		}
+
 		// Make sure all needed values are valid, make user re-enter
 		// if not
 		if !setup.CheckValid() {
@@ -122,6 +141,13 @@ func sendForm(w http.ResponseWriter, r *http.Request) {
 				setup.WebConfig.ExposeAPI, setup.WebConfig.ApiIP, setup.WebConfig.ApiPort,
 				setup.WebConfig.Title, setup.WebConfig.Title, setup.Mysql.Host, setup.Mysql.Port,
 				setup.Mysql.User, setup.Mysql.Password, setup.Mysql.Database)
+		} else if setup.DatabasePreference == "postgres" {
+			printReadyConfig = fmt.Sprintf(config.PrintSetupConfig(setup.DatabasePreference),
+				setup.Interval, setup.CheckTimeout,
+				setup.WebConfig.ListenIP, setup.WebConfig.ListenPort,
+				setup.WebConfig.ExposeAPI, setup.WebConfig.ApiIP, setup.WebConfig.ApiPort,
+				setup.WebConfig.Title, setup.WebConfig.Title, setup.Postgres.Host, setup.Postgres.Port,
+				setup.Postgres.User, setup.Postgres.Password, setup.Postgres.Database, setup.Postgres.SSLMode)
 		}
 
 		http.Redirect(w, r, "/confirm", http.StatusSeeOther)
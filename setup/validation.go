@@ -12,7 +12,8 @@ func (s *Setup) CheckValid() bool {
 
 	numberPatt := regexp.MustCompile(`\d`)
 	yesnoPatt := regexp.MustCompile(`(yes|no)`)
-	databasePatt := regexp.MustCompile(`(sqlite|mysql)`)
+	databasePatt := regexp.MustCompile(`^(sqlite|mysql|postgres)$`)
+	sslModePatt := regexp.MustCompile(`^(disable|require|verify-ca|verify-full)$`)
 	ipPatt := regexp.MustCompile(`(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`)
 	ipDomainPatt := regexp.MustCompile(`((?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)|((?:[\w-]+\.)*([\w-]{1,63})(?:\.(?:\w{3}|\w{2}))(?:$|\/)))`)
 
@@ -77,6 +78,25 @@ func (s *Setup) CheckValid() bool {
 			if strings.TrimSpace(s.Mysql.Database) == "" {
 				s.Errors["mydb"] = "This field must be filled and the database must already be created on the server"
 			}
+		} else if s.DatabasePreference == "postgres" {
+			if !ipDomainPatt.Match([]byte(s.Postgres.Host)) {
+				s.Errors["pgurl"] = "A valid hostname or IP must be entered"
+			}
+			if !numberPatt.Match([]byte(s.Postgres.Port)) {
+				s.Errors["pgport"] = "A valid number must be entered"
+			}
+			if strings.TrimSpace(s.Postgres.User) == "" {
+				s.Errors["pguser"] = "This field must be filled"
+			}
+			if strings.TrimSpace(s.Postgres.Password) == "" {
+				s.Errors["pgpass"] = "This field must be filled"
+			}
+			if strings.TrimSpace(s.Postgres.Database) == "" {
+				s.Errors["pgdb"] = "This field must be filled and the database must already be created on the server"
+			}
+			if !sslModePatt.Match([]byte(s.Postgres.SSLMode)) {
+				s.Errors["pgsslmode"] = "An SSL mode must be selected from the dropdown list"
+			}
 		}
 	}
 
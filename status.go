@@ -0,0 +1,65 @@
+package gogios
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statuses that a check can be in after it runs
+const (
+	StatusSuccess  = "Success"
+	StatusWarning  = "Warning"
+	StatusFailed   = "Failed"
+	StatusTimedOut = "Timed Out"
+)
+
+// Severity levels used to rank statuses. Anything that is not a success or a
+// warning is treated as critical.
+const (
+	SeverityOK = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+// Severity returns how serious a status is
+func Severity(status string) int {
+	switch status {
+	case StatusSuccess:
+		return SeverityOK
+	case StatusWarning:
+		return SeverityWarning
+	default:
+		return SeverityCritical
+	}
+}
+
+// ParseSeverity converts a severity name from the config file (ok, warning or
+// critical) into one of the Severity levels. Status names are accepted too.
+func ParseSeverity(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "ok", "success":
+		return SeverityOK, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "critical", "failed", "timed out":
+		return SeverityCritical, nil
+	default:
+		return SeverityOK, fmt.Errorf("unknown severity %q, must be ok, warning or critical", name)
+	}
+}
+
+// StatusCode converts a status into the numbers used by Nagios plugins and
+// most monitoring tools: 0 for OK, 1 for warning, 2 for critical and 3 for
+// unknown, which is what a check that timed out is
+func StatusCode(status string) int {
+	switch status {
+	case StatusSuccess:
+		return 0
+	case StatusWarning:
+		return 1
+	case StatusFailed:
+		return 2
+	default:
+		return 3
+	}
+}
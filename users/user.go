@@ -1,6 +1,8 @@
 package users
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,29 +13,26 @@ import (
 )
 
 // CreateUser - Add a new user to configured gogios databases
-func CreateUser(user gogios.User, conf *config.Config) error {
+func CreateUser(ctx context.Context, user gogios.User) error {
 	pass, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
 	user.Password = string(pass)
-	for _, database := range conf.Databases {
-		err := database.Database.AddUser(user)
-		if err != nil {
-			return err
-		}
-	}
 
-	return nil
+	return config.DB.AddUser(ctx, user)
 }
 
-// Login checks the provided username/password combo against the first configured database
-func Login(username, password string, db gogios.Database) map[string]interface{} {
-	user, err := db.GetUser(username)
-	if err != nil {
+// Login checks the provided username/password combo against the first healthy database
+func Login(ctx context.Context, username, password string, db gogios.Database) map[string]interface{} {
+	user, err := db.GetUser(ctx, username)
+	if errors.Is(err, gogios.ErrNotFound) {
 		var resp = map[string]interface{}{"status": false, "message": "Username not found"}
 		return resp
+	} else if err != nil {
+		var resp = map[string]interface{}{"status": false, "message": "Could not read users from the database"}
+		return resp
 	}
 
 	expiresAt := jwt.NewNumericDate(time.Now().Add(time.Minute * 100000))
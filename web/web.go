@@ -1,6 +1,8 @@
 package web
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"net/http"
 	"os"
@@ -27,11 +29,16 @@ var (
 )
 
 type checks struct {
-	Title  string
-	Status string
-	Output string
-	Ratio  float64
-	Asof   time.Time
+	Title     string
+	Status    string
+	Output    string
+	Stderr    string
+	Truncated bool
+	ExitCode  int
+	Duration  time.Duration
+	Expires   string // Certificate expiry of tls checks, empty for the rest
+	Ratio     float64
+	Asof      time.Time
 }
 
 // ViewData is used to replace variables in the HTML templates
@@ -44,7 +51,7 @@ type ViewData struct {
 }
 
 func checksPage(w http.ResponseWriter, r *http.Request) {
-	table := genTable()
+	table := genTable(r.Context())
 
 	// Inject data into template
 	vd := ViewData{
@@ -59,7 +66,7 @@ func checksPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func mainPage(w http.ResponseWriter, r *http.Request) {
-	table := genTable()
+	table := genTable(r.Context())
 
 	// Inject data into template
 	vd := ViewData{
@@ -86,13 +93,13 @@ func ServePage() {
 	webLogger = logger.Init("WebLog", config.Conf.Options.Verbose, true, log)
 	defer webLogger.Close()
 
-	primaryDB = config.Conf.Databases[0].Database
+	primaryDB = config.DB
 	refresh = int(config.Conf.Options.Interval.Duration.Minutes())
 	title = config.Conf.WebOptions.Title
 	navbar = config.Conf.WebOptions.NavBar
 	logo = config.Conf.WebOptions.Logo
 
-	data, err = config.Conf.Databases[0].Database.GetAllChecks()
+	data, err = primaryDB.GetAllChecks(context.Background())
 	if err != nil {
 		webLogger.Errorf("Failed to read rows from database. Error:\n%s", err.Error())
 	}
@@ -145,27 +152,44 @@ func render(w http.ResponseWriter, filename string, data interface{}, logger *lo
 func UpdateWebData() {
 	var err error
 
-	data, err = config.Conf.Databases[0].Database.GetAllChecks()
+	checks, err := config.DB.GetAllChecks(context.Background())
 	if err != nil {
+		// Keep showing the last known data rather than an empty table
 		webLogger.Errorf("Failed to update webpage data from database. Error:\n%s", err.Error())
+		return
 	}
+	data = checks
 }
 
-func genTable() []checks {
+func genTable(ctx context.Context) []checks {
 	var table []checks
 
 	for i := 0; i < len(data); i++ {
-		output, err := primaryDB.GetCheckHistory(data[i], 1)
+		var last gogios.CheckHistory
+		history, err := primaryDB.GetCheckHistory(ctx, data[i], 1)
 		if err != nil {
 			webLogger.Errorf("Error getting history of check:\n%v", err.Error())
+		} else if len(history) > 0 {
+			last = history[0]
+		}
+
+		var expires string
+		if data[i].Expires != nil {
+			days := int(math.Floor(time.Until(*data[i].Expires).Hours() / 24))
+			expires = fmt.Sprintf("%s (%d days)", data[i].Expires.Format("2006-01-02"), days)
 		}
 
 		table = append(table, checks{
-			Title:  data[i].Title,
-			Status: data[i].Status,
-			Output: output[0].Output,
-			Ratio:  math.Round((float64(data[i].GoodCount) / float64(data[i].TotalCount) * 100)),
-			Asof:   data[i].Asof,
+			Title:     data[i].Title,
+			Status:    data[i].Status,
+			Output:    last.Output,
+			Stderr:    last.Stderr,
+			Truncated: last.Truncated,
+			ExitCode:  data[i].ExitCode,
+			Duration:  data[i].Duration.Round(time.Millisecond),
+			Expires:   expires,
+			Ratio:     math.Round((float64(data[i].GoodCount) / float64(data[i].TotalCount) * 100)),
+			Asof:      data[i].Asof,
 		})
 	}
 